@@ -0,0 +1,142 @@
+package donothing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// An ExecContext is passed to a step's automated implementation when the step is executed.
+//
+// It gives the implementation access to the values of the step's inputs, and lets it set the values
+// of the step's outputs.
+type ExecContext struct {
+	ctx  context.Context
+	pcd  *Procedure
+	step *Step
+	rep  *repetition
+
+	// The output values set so far by the step's implementation.
+	outputs map[string]string
+}
+
+// Context returns the context.Context for the execution.
+//
+// Automated implementations that do slow or blocking work must stop early when the context is
+// done. The context is done when the step's timeout expires or the execution is interrupted, and
+// donothing stops waiting for the implementation at that point; an implementation that ignores the
+// context keeps running in the background.
+func (ec *ExecContext) Context() context.Context {
+	return ec.ctx
+}
+
+// StepName returns the absolute name of the step being executed.
+func (ec *ExecContext) StepName() string {
+	return ec.step.AbsoluteName()
+}
+
+// GetInput returns the value of the given input.
+//
+// If the step repeats for each item in a list, GetInput returns the current item for the list's
+// name. If no value has been collected for the input, GetInput returns "".
+func (ec *ExecContext) GetInput(name string) string {
+	if ec.rep != nil && name == ec.rep.ListName {
+		return ec.rep.Item
+	}
+	value, _ := ec.pcd.getValue(name)
+	return value
+}
+
+// SetOutput sets the value of the given output.
+//
+// name must be the name of one of the step's outputs. Output values are recorded once the step's
+// implementation returns successfully.
+func (ec *ExecContext) SetOutput(name string, value string) {
+	ec.outputs[name] = value
+}
+
+// runHook calls the given step callback, if it's not nil, with a fresh ExecContext.
+func (pcd *Procedure) runHook(ctx context.Context, fn func(*ExecContext), step *Step, rep *repetition) {
+	if fn == nil {
+		return
+	}
+	fn(&ExecContext{
+		ctx:     ctx,
+		pcd:     pcd,
+		step:    step,
+		rep:     rep,
+		outputs: make(map[string]string),
+	})
+}
+
+// runAutomated runs step's automated implementation and records the outputs it sets.
+//
+// An error is returned if the implementation returns an error, sets an output that the step
+// doesn't declare, or fails to set an output that the step does declare. An error is also returned
+// if the step has a timeout and the implementation doesn't return within it, or if ctx is done
+// before then, in which case the error wraps ErrInterrupted.
+func (pcd *Procedure) runAutomated(ctx context.Context, step *Step, rep *repetition) error {
+	parent := ctx
+	if step.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.timeout)
+		defer cancel()
+	}
+
+	ec := &ExecContext{
+		ctx:     ctx,
+		pcd:     pcd,
+		step:    step,
+		rep:     rep,
+		outputs: make(map[string]string),
+	}
+
+	fmt.Fprintf(pcd.stdout, "\n\nRunning automated step '%s'\n", step.AbsoluteName())
+	if step.timeout > 0 {
+		// Run the implementation in the background so we can stop waiting for it when the timeout
+		// expires, even if it ignores its context.
+		done := make(chan error, 1)
+		go func() { done <- step.fn(ec) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("Automated step '%s' failed: %w", step.AbsoluteName(), err)
+			}
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				return fmt.Errorf("Interrupted at step '%s': %w", step.AbsoluteName(), ErrInterrupted)
+			}
+			return fmt.Errorf("Automated step '%s' did not finish within %s: %w", step.AbsoluteName(), step.timeout, ctx.Err())
+		}
+	} else if err := step.fn(ec); err != nil {
+		return fmt.Errorf("Automated step '%s' failed: %w", step.AbsoluteName(), err)
+	}
+
+	declared := make(map[string]bool)
+	for _, outputDef := range step.GetOutputDefs() {
+		declared[outputDef.Name] = true
+		value, ok := ec.outputs[outputDef.Name]
+		if !ok {
+			return fmt.Errorf("Automated step '%s' did not set output '%s'", step.AbsoluteName(), outputDef.Name)
+		}
+		if _, err := strconv.Atoi(value); outputDef.ValueType == "int" && err != nil {
+			return fmt.Errorf("Automated step '%s' set int output '%s' to non-integer value '%s'", step.AbsoluteName(), outputDef.Name, value)
+		}
+	}
+	for name := range ec.outputs {
+		if !declared[name] {
+			return fmt.Errorf("Automated step '%s' set undeclared output '%s'", step.AbsoluteName(), name)
+		}
+	}
+
+	secrets := pcd.secretNames()
+	for _, outputDef := range step.GetOutputDefs() {
+		value := ec.outputs[outputDef.Name]
+		pcd.setValue(outputDef.Name, value)
+		if secrets[outputDef.Name] {
+			value = RedactedValue
+		}
+		fmt.Fprintf(pcd.stdout, "  - %s: %s\n", outputDef.Name, value)
+	}
+	return nil
+}
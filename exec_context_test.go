@@ -0,0 +1,278 @@
+package donothing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An automated step's function should receive its inputs and set its outputs without prompting.
+func TestProcedure_Execute_Automated(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Greet the user")
+	pcd.AddStep(func(step *Step) {
+		step.Name("getName")
+		step.Short("Get the user's name")
+		step.OutputString("Name", "The user's name")
+		step.Automate(func(ec *ExecContext) error {
+			ec.SetOutput("Name", "Alice")
+			return nil
+		})
+	})
+	var greeting string
+	pcd.AddStep(func(step *Step) {
+		step.Name("greet")
+		step.Short("Greet the user")
+		step.InputString("Name", true)
+		step.Automate(func(ec *ExecContext) error {
+			assert.Equal("root.greet", ec.StepName())
+			assert.NotNil(ec.Context())
+			greeting = "Hello, " + ec.GetInput("Name")
+			return nil
+		})
+	})
+
+	// Only the root step is manual
+	pcd.stdin = strings.NewReader("\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Equal("Hello, Alice", greeting)
+	assert.Contains(stdout.String(), "Running automated step 'root.getName'")
+	assert.Contains(stdout.String(), "  - Name: Alice")
+	assert.Equal(1, strings.Count(stdout.String(), "[Enter] to proceed"))
+}
+
+// Execute should stop with an error if an automated step fails or mishandles its outputs.
+func TestProcedure_Execute_Automated_Error(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		Fn     func(*ExecContext) error
+		ErrMsg string
+	}
+
+	testCases := []testCase{
+		testCase{
+			Fn: func(ec *ExecContext) error {
+				return errors.New("kaboom")
+			},
+			ErrMsg: "kaboom",
+		},
+		testCase{
+			Fn: func(ec *ExecContext) error {
+				return nil
+			},
+			ErrMsg: "did not set output 'Name'",
+		},
+		testCase{
+			Fn: func(ec *ExecContext) error {
+				ec.SetOutput("Name", "Alice")
+				ec.SetOutput("Nickname", "Al")
+				return nil
+			},
+			ErrMsg: "set undeclared output 'Nickname'",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Greet the user")
+		pcd.AddStep(func(step *Step) {
+			step.Name("getName")
+			step.Short("Get the user's name")
+			step.OutputString("Name", "The user's name")
+			step.Automate(tc.Fn)
+		})
+		pcd.stdin = strings.NewReader("\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		err := pcd.Execute()
+		if assert.NotNil(err) {
+			assert.Contains(err.Error(), tc.ErrMsg)
+		}
+		assert.NotContains(stdout.String(), "Done.")
+	}
+}
+
+// An automated step that runs past its timeout should fail, whether or not it heeds its context.
+func TestProcedure_Execute_Automated_Timeout(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		Fn       func(*ExecContext) error
+		ErrorExp bool
+	}
+
+	testCases := []testCase{
+		// Finishes in time
+		testCase{
+			Fn: func(ec *ExecContext) error {
+				ec.SetOutput("Status", "ok")
+				return nil
+			},
+			ErrorExp: false,
+		},
+		// Heeds its context
+		testCase{
+			Fn: func(ec *ExecContext) error {
+				<-ec.Context().Done()
+				return ec.Context().Err()
+			},
+			ErrorExp: true,
+		},
+		// Ignores its context
+		testCase{
+			Fn: func(ec *ExecContext) error {
+				time.Sleep(time.Second)
+				ec.SetOutput("Status", "ok")
+				return nil
+			},
+			ErrorExp: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert := assert.New(t)
+
+		pcd := NewProcedure()
+		pcd.Short("Check the service")
+		pcd.AddStep(func(step *Step) {
+			step.Name("check")
+			step.Short("Check the service's status")
+			step.OutputString("Status", "The service's status")
+			step.Automate(tc.Fn)
+			step.Timeout(50 * time.Millisecond)
+		})
+
+		pcd.stdin = strings.NewReader("\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		start := time.Now()
+		err := pcd.Execute()
+		assert.True(time.Since(start) < 500*time.Millisecond)
+		if !tc.ErrorExp {
+			assert.Nil(err)
+			continue
+		}
+		assert.True(errors.Is(err, context.DeadlineExceeded))
+		assert.Contains(err.Error(), "Automated step 'root.check'")
+	}
+}
+
+// An automated step with a timeout should report an interruption, not a timeout, when the
+// execution is interrupted while it runs.
+func TestProcedure_RunAutomated_Interrupted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+
+	pcd := NewProcedure()
+	pcd.Short("Check the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("check")
+		step.Short("Check the service's status")
+		step.Automate(func(ec *ExecContext) error {
+			// Ignores its context
+			cancel()
+			<-release
+			return nil
+		})
+		step.Timeout(time.Minute)
+	})
+
+	pcd.stdout = &bytes.Buffer{}
+
+	step, err := pcd.GetStepByName("root.check")
+	assert.Nil(err)
+	err = pcd.runAutomated(ctx, step, nil)
+	assert.True(errors.Is(err, ErrInterrupted))
+	assert.False(errors.Is(err, context.DeadlineExceeded))
+	assert.NotContains(err.Error(), "did not finish within")
+}
+
+// BeforePrompt and AfterProceed callbacks should run in order around the user's interaction with a
+// manual step, and around the implementation of an automated step.
+func TestProcedure_Execute_Hooks(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var stdout bytes.Buffer
+	events := make([]string, 0)
+
+	pcd := NewProcedure()
+	pcd.Short("Fix the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+		step.BeforePrompt(func(ec *ExecContext) {
+			events = append(events, "before "+ec.StepName())
+			// Only the root step's prompt should have been shown so far.
+			assert.Equal(1, strings.Count(stdout.String(), "[Enter] to proceed"))
+		})
+		step.AfterProceed(func(ec *ExecContext) {
+			events = append(events, "after "+ec.StepName()+" "+ec.GetInput("HostName"))
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.Automate(func(ec *ExecContext) error {
+			events = append(events, "automate "+ec.StepName())
+			return nil
+		})
+		step.BeforePrompt(func(ec *ExecContext) {
+			events = append(events, "before "+ec.StepName())
+		})
+		step.AfterProceed(func(ec *ExecContext) {
+			events = append(events, "after "+ec.StepName())
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("skipped")
+		step.Short("Skip this step")
+		step.AfterProceed(func(ec *ExecContext) {
+			events = append(events, "after "+ec.StepName())
+		})
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.findHost
+		"",
+		"web-1",
+		// root.skipped
+		"skip",
+	}, "\n") + "\n")
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Equal([]string{
+		"before root.findHost",
+		"after root.findHost web-1",
+		"before root.fixHost",
+		"automate root.fixHost",
+		"after root.fixHost",
+	}, events)
+}
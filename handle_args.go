@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
@@ -29,8 +30,12 @@ func (cli *DefaultCLI) Usage() string {
 
 {{end -}}
 OPTIONS: 
-    --markdown    Instead of executing the procedure, print its Markdown documentation to stdout
-    --help        Print usage message`
+    --format=FORMAT  Instead of executing the procedure, print its documentation to stdout in the
+                     given format: markdown, html, text, json, or mermaid
+    --markdown       Same as --format=markdown
+    --sequence=FILE  Instead of STEP_NAME, execute the steps named in FILE, one absolute step name
+                     per line, in that order and without their descendants
+    --help           Print usage message`
 	//tpl := template.Must(template.New("usage").Parse(tplStr))
 	tpl, err := template.New("usage").Parse(tplStr)
 	if err != nil {
@@ -54,7 +59,14 @@ func (cli *DefaultCLI) Run(args []string) error {
 
 	flags := make([]string, 0)
 	nonFlags := make([]string, 0)
-	for _, arg := range args[1:] {
+	rest := args[1:]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if arg == "--sequence" && i+1 < len(rest) {
+			// Also accept the file as a separate argument.
+			arg = "--sequence=" + rest[i+1]
+			i++
+		}
 		if strings.IndexRune(arg, '-') == 0 {
 			flags = append(flags, arg)
 		} else {
@@ -76,8 +88,20 @@ func (cli *DefaultCLI) Run(args []string) error {
 	opts := map[string]bool{
 		"--markdown": false,
 	}
+	// The value of --format, or "" if it wasn't passed.
+	format := ""
+	// The value of --sequence, or "" if it wasn't passed.
+	sequenceFile := ""
 	for _, flag := range flags {
-		if _, ok := opts[flag]; ok {
+		if strings.HasPrefix(flag, "--sequence=") {
+			sequenceFile = strings.TrimPrefix(flag, "--sequence=")
+		} else if strings.HasPrefix(flag, "--format=") {
+			format = strings.TrimPrefix(flag, "--format=")
+			if _, ok := cli.renderers()[format]; !ok {
+				fmt.Fprintln(cli.out, cli.Usage())
+				return fmt.Errorf("Unknown format '%s'", format)
+			}
+		} else if _, ok := opts[flag]; ok {
 			opts[flag] = true
 		} else {
 			fmt.Fprintln(cli.out, cli.Usage())
@@ -85,6 +109,21 @@ func (cli *DefaultCLI) Run(args []string) error {
 		}
 	}
 
+	if opts["--markdown"] && format == "" {
+		format = "markdown"
+	}
+	if sequenceFile != "" {
+		if format != "" || len(nonFlags) > 0 {
+			fmt.Fprintln(cli.out, cli.Usage())
+			return fmt.Errorf("--sequence can't be combined with STEP_NAME or a documentation format")
+		}
+		stepNames, err := readSequenceFile(sequenceFile)
+		if err != nil {
+			return err
+		}
+		return cli.Pcd.ExecuteSequence(stepNames)
+	}
+
 	if len(nonFlags) == 0 && cli.DefaultStep == "" {
 		fmt.Fprintln(cli.out, cli.Usage())
 		return fmt.Errorf("Must specify STEP_NAME")
@@ -100,17 +139,104 @@ func (cli *DefaultCLI) Run(args []string) error {
 		stepName = nonFlags[0]
 	}
 
-	if opts["--markdown"] {
-		return cli.Pcd.RenderStep(cli.out, stepName)
+	if format != "" {
+		return cli.renderers()[format](cli.out, stepName)
+	}
+	if len(nonFlags) == 0 {
+		cli.warnUnreachable()
 	}
 	return cli.Pcd.ExecuteStep(stepName)
 }
 
+// readSequenceFile reads the step names listed in the given --sequence file.
+//
+// The file lists one absolute step name per line. Blank lines, and lines starting with "#", are
+// ignored.
+func readSequenceFile(name string) ([]string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read sequence file: %w", err)
+	}
+	stepNames := make([]string, 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		line = normalizeLine(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		stepNames = append(stepNames, line)
+	}
+	return stepNames, nil
+}
+
+// renderers returns the Procedure methods that render documentation, keyed by the value of the
+// --format flag that selects them.
+func (cli *DefaultCLI) renderers() map[string]func(io.Writer, string) error {
+	return map[string]func(io.Writer, string) error{
+		"markdown": cli.Pcd.RenderStep,
+		"html":     cli.Pcd.RenderStepHTML,
+		"text":     cli.Pcd.RenderStepText,
+		"json":     cli.Pcd.RenderStepJSON,
+		"mermaid":  cli.Pcd.RenderStepMermaid,
+	}
+}
+
+// UnreachableSteps returns the absolute names of steps that can't be executed by the default
+// invocation, i.e. by running the CLI without specifying STEP_NAME.
+//
+// When DefaultStep refers to a step other than the root, only that step and its descendants are
+// executed by the default invocation. UnreachableSteps returns every other step in the procedure,
+// including the ancestors of DefaultStep, since their prompts are never shown either. Steps are
+// returned in walk order.
+//
+// If DefaultStep is "", every step must be named explicitly, so UnreachableSteps returns an empty
+// slice. If DefaultStep doesn't refer to a step in the procedure, an error is returned.
+func (cli *DefaultCLI) UnreachableSteps() ([]string, error) {
+	unreachable := make([]string, 0)
+	if cli.DefaultStep == "" {
+		return unreachable, nil
+	}
+
+	defaultStep, err := cli.Pcd.GetStepByName(cli.DefaultStep)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[*Step]bool)
+	defaultStep.Walk(func(step *Step) error {
+		reachable[step] = true
+		return nil
+	})
+
+	cli.Pcd.rootStep.Walk(func(step *Step) error {
+		if !reachable[step] {
+			unreachable = append(unreachable, step.AbsoluteName())
+		}
+		return nil
+	})
+	return unreachable, nil
+}
+
+// warnUnreachable prints a warning listing the steps that the default invocation won't execute, if
+// there are any.
+func (cli *DefaultCLI) warnUnreachable() {
+	unreachable, err := cli.UnreachableSteps()
+	if err != nil || len(unreachable) == 0 {
+		return
+	}
+	fmt.Fprintf(
+		cli.out,
+		"Warning: default step '%s' does not include these steps: %s\n\n",
+		cli.DefaultStep,
+		strings.Join(unreachable, ", "),
+	)
+}
+
 // NewDefaultCLI returns a DefaultCLI instance initialized with the given executable name.
 //
 // execName is the name of the executable that has imported donothing. pcd is the procedure to run
 // actions against. defaultStep is the step to execute if the user doesn't specify STEP_NAME; if
-// defaultStep is "", omission of STEP_NAME from the invocation will trigger an error.
+// defaultStep is "", omission of STEP_NAME from the invocation will trigger an error. Otherwise,
+// defaultStep must be the absolute name of a step in pcd.
 func NewDefaultCLI(execName string, pcd *Procedure, defaultStep string) (*DefaultCLI, error) {
 	if pcd == nil {
 		return nil, fmt.Errorf("failed to initialize default CLI: procedure must not be nil")
@@ -118,6 +244,11 @@ func NewDefaultCLI(execName string, pcd *Procedure, defaultStep string) (*Defaul
 	if _, err := pcd.Check(); err != nil {
 		return nil, err
 	}
+	if defaultStep != "" {
+		if _, err := pcd.GetStepByName(defaultStep); err != nil {
+			return nil, fmt.Errorf("failed to initialize default CLI: invalid default step: %w", err)
+		}
+	}
 	return &DefaultCLI{
 		ExecName:    execName,
 		Pcd:         pcd,
@@ -2,6 +2,9 @@ package donothing
 
 import (
 	"bytes"
+	"io/ioutil"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,14 +26,18 @@ func TestDefaultCLI_Usage(t *testing.T) {
 	testCases := []testCase{
 		// With default step specified
 		testCase{
-			DefaultStep: "root/stepName",
+			DefaultStep: "root",
 			Exp: `USAGE: foo [options] [STEP_NAME]
 
 Procedure's short description
 
 OPTIONS: 
-    --markdown    Instead of executing the procedure, print its Markdown documentation to stdout
-    --help        Print usage message`,
+    --format=FORMAT  Instead of executing the procedure, print its documentation to stdout in the
+                     given format: markdown, html, text, json, or mermaid
+    --markdown       Same as --format=markdown
+    --sequence=FILE  Instead of STEP_NAME, execute the steps named in FILE, one absolute step name
+                     per line, in that order and without their descendants
+    --help           Print usage message`,
 		},
 		// Without default step
 		testCase{
@@ -40,8 +47,12 @@ OPTIONS:
 Procedure's short description
 
 OPTIONS: 
-    --markdown    Instead of executing the procedure, print its Markdown documentation to stdout
-    --help        Print usage message`,
+    --format=FORMAT  Instead of executing the procedure, print its documentation to stdout in the
+                     given format: markdown, html, text, json, or mermaid
+    --markdown       Same as --format=markdown
+    --sequence=FILE  Instead of STEP_NAME, execute the steps named in FILE, one absolute step name
+                     per line, in that order and without their descendants
+    --help           Print usage message`,
 		},
 	}
 
@@ -53,6 +64,55 @@ OPTIONS:
 	}
 }
 
+// NewDefaultCLI should reject a default step that isn't in the procedure.
+func TestNewDefaultCLI_DefaultStep(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Procedure's short description")
+	pcd.AddStep(func(step *Step) {
+		step.Name("blahBlah")
+		step.Short("the blahBlah step")
+	})
+
+	type testCase struct {
+		DefaultStep string
+		ErrorExp    bool
+	}
+
+	testCases := []testCase{
+		testCase{
+			DefaultStep: "root.blahBlah",
+			ErrorExp:    false,
+		},
+		testCase{
+			DefaultStep: "",
+			ErrorExp:    false,
+		},
+		testCase{
+			DefaultStep: "root.nonexistent",
+			ErrorExp:    true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		cli, err := NewDefaultCLI("foo", pcd, tc.DefaultStep)
+		if tc.ErrorExp {
+			assert.Nil(cli)
+			if assert.NotNil(err) {
+				assert.Contains(err.Error(), "invalid default step")
+				assert.Contains(err.Error(), "root.nonexistent")
+			}
+			continue
+		}
+		assert.Nil(err)
+		assert.Equal(tc.DefaultStep, cli.DefaultStep)
+	}
+}
+
 // DefaultCLI should print usage when --help is passed or the args are wrong.
 func TestDefaultCLI_PrintUsage(t *testing.T) {
 	t.Parallel()
@@ -194,8 +254,11 @@ func TestDefaultCLI_Render(t *testing.T) {
 	for i, tc := range testCases {
 		t.Logf("test case %d", i)
 
-		cli, err := NewDefaultCLI("foo", pcd, tc.DefaultStep)
+		// NewDefaultCLI rejects a nonexistent default step, so set it afterward to make sure Run
+		// copes too.
+		cli, err := NewDefaultCLI("foo", pcd, "")
 		assert.Nil(err)
+		cli.DefaultStep = tc.DefaultStep
 
 		var buf bytes.Buffer
 		cli.out = &buf
@@ -211,3 +274,281 @@ func TestDefaultCLI_Render(t *testing.T) {
 		tc.Match(buf.String())
 	}
 }
+
+// DefaultCLI should render documentation in the format given by --format
+func TestDefaultCLI_Render_Format(t *testing.T) {
+	t.Parallel()
+
+	pcd := NewProcedure()
+	pcd.Short("Procedure's short description")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+	})
+
+	type testCase struct {
+		// os.Args
+		Args []string
+		// Strings that should appear in the output of cli.Run
+		Contains []string
+		// Whether an error is expected from cli.Run
+		ErrorExp bool
+	}
+
+	testCases := []testCase{
+		testCase{
+			Args:     []string{"foo", "--format=markdown"},
+			Contains: []string{"# Procedure's short description", "## (0) Find the host"},
+		},
+		testCase{
+			Args:     []string{"foo", "--markdown"},
+			Contains: []string{"# Procedure's short description", "## (0) Find the host"},
+		},
+		testCase{
+			Args: []string{"foo", "--format=html"},
+			Contains: []string{
+				"<h1>Procedure&#39;s short description</h1>",
+				"<h2>(0) Find the host</h2>",
+				"<li><code>HostName</code> (string): Name of the host</li>",
+			},
+		},
+		testCase{
+			Args:     []string{"foo", "--format=text"},
+			Contains: []string{"Procedure's short description\n\n(0) Find the host", "  - `HostName`"},
+		},
+		testCase{
+			Args:     []string{"foo", "--format=json"},
+			Contains: []string{`"name": "root.findHost"`, `"short": "Find the host"`},
+		},
+		testCase{
+			Args: []string{"foo", "--format=mermaid"},
+			Contains: []string{
+				"flowchart TD",
+				`step1["(0) Find the host"]`,
+				"step0 --> step1",
+				"step1 -.->|HostName| step2",
+			},
+		},
+		testCase{
+			Args:     []string{"foo", "--format=pdf"},
+			Contains: []string{"USAGE:"},
+			ErrorExp: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert := assert.New(t)
+
+		cli, err := NewDefaultCLI("foo", pcd, "root")
+		assert.Nil(err)
+
+		var buf bytes.Buffer
+		cli.out = &buf
+		err = cli.Run(tc.Args)
+		assert.Equal(tc.ErrorExp, err != nil)
+		for _, exp := range tc.Contains {
+			assert.Contains(buf.String(), exp)
+		}
+	}
+}
+
+// DefaultCLI.UnreachableSteps should report steps outside of the default step's subtree.
+func TestDefaultCLI_UnreachableSteps(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Procedure's short description")
+	pcd.AddStep(func(step *Step) {
+		step.Name("left")
+		step.Short("Left subtree")
+		step.AddStep(func(step *Step) {
+			step.Name("leftChild")
+			step.Short("Child of left subtree")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("right")
+		step.Short("Right subtree")
+		step.AddStep(func(step *Step) {
+			step.Name("rightChild")
+			step.Short("Child of right subtree")
+		})
+	})
+
+	type testCase struct {
+		DefaultStep string
+		Exp         []string
+		ErrorExp    bool
+	}
+
+	testCases := []testCase{
+		testCase{
+			DefaultStep: "",
+			Exp:         []string{},
+		},
+		testCase{
+			DefaultStep: "root",
+			Exp:         []string{},
+		},
+		testCase{
+			DefaultStep: "root.left",
+			Exp:         []string{"root", "root.right", "root.right.rightChild"},
+		},
+		testCase{
+			DefaultStep: "root.right.rightChild",
+			Exp:         []string{"root", "root.left", "root.left.leftChild", "root.right"},
+		},
+		testCase{
+			DefaultStep: "root.nonexistent",
+			ErrorExp:    true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		// NewDefaultCLI rejects a nonexistent default step, so set it afterward to make sure
+		// UnreachableSteps copes too.
+		cli, err := NewDefaultCLI("foo", pcd, "")
+		assert.Nil(err)
+		cli.DefaultStep = tc.DefaultStep
+
+		unreachable, err := cli.UnreachableSteps()
+		if tc.ErrorExp {
+			assert.NotNil(err)
+			continue
+		}
+		assert.Nil(err)
+		assert.Equal(tc.Exp, unreachable)
+	}
+}
+
+// DefaultCLI.Run should warn about unreachable steps when executing the default step.
+func TestDefaultCLI_Run_WarnUnreachable(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		Args        []string
+		DefaultStep string
+		WarningExp  bool
+	}
+
+	testCases := []testCase{
+		testCase{
+			Args:        []string{"foo"},
+			DefaultStep: "root.left",
+			WarningExp:  true,
+		},
+		testCase{
+			Args:        []string{"foo"},
+			DefaultStep: "root",
+			WarningExp:  false,
+		},
+		// The user named the step explicitly, so there's nothing to warn about
+		testCase{
+			Args:        []string{"foo", "root.left"},
+			DefaultStep: "root.left",
+			WarningExp:  false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Procedure's short description")
+		pcd.AddStep(func(step *Step) {
+			step.Name("left")
+			step.Short("Left subtree")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("right")
+			step.Short("Right subtree")
+		})
+
+		cli, err := NewDefaultCLI("foo", pcd, tc.DefaultStep)
+		assert.Nil(err)
+
+		var buf bytes.Buffer
+		cli.out = &buf
+		pcd.stdout = &buf
+		pcd.stdin = strings.NewReader("\n\n\n")
+		assert.Nil(cli.Run(tc.Args))
+		if tc.WarningExp {
+			assert.Contains(buf.String(), "Warning: default step 'root.left' does not include these steps: root, root.right\n")
+		} else {
+			assert.NotContains(buf.String(), "Warning:")
+		}
+	}
+}
+
+// DefaultCLI.Run should execute the steps listed in a --sequence file, in the file's order.
+func TestDefaultCLI_Run_Sequence(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	seqFile := path.Join(t.TempDir(), "sequence")
+	assert.Nil(ioutil.WriteFile(seqFile, []byte("# Fix the host first\nroot.fixHost\n\nroot.findHost\n"), 0644))
+	badSeqFile := path.Join(t.TempDir(), "sequence")
+	assert.Nil(ioutil.WriteFile(badSeqFile, []byte("root.fixHost\nroot.nonexistent\n"), 0644))
+
+	type testCase struct {
+		Args     []string
+		ErrorExp bool
+	}
+
+	testCases := []testCase{
+		testCase{Args: []string{"foo", "--sequence=" + seqFile}},
+		testCase{Args: []string{"foo", "--sequence", seqFile}},
+		testCase{Args: []string{"foo", "--sequence=" + badSeqFile}, ErrorExp: true},
+		testCase{Args: []string{"foo", "--sequence=" + path.Join(t.TempDir(), "missing")}, ErrorExp: true},
+		testCase{Args: []string{"foo", "--sequence=" + seqFile, "root"}, ErrorExp: true},
+		testCase{Args: []string{"foo", "--sequence=" + seqFile, "--markdown"}, ErrorExp: true},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Fix the host")
+		pcd.AddStep(func(step *Step) {
+			step.Name("findHost")
+			step.Short("Find the host")
+			step.OutputString("HostName", "Name of the host")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("fixHost")
+			step.Short("Repair the host")
+		})
+
+		cli, err := NewDefaultCLI("foo", pcd, "root")
+		assert.Nil(err)
+
+		var buf bytes.Buffer
+		cli.out = &buf
+		pcd.stdout = &buf
+		pcd.stdin = strings.NewReader("\n\nweb-1\n")
+		err = cli.Run(tc.Args)
+		if tc.ErrorExp {
+			assert.NotNil(err)
+			continue
+		}
+		assert.Nil(err)
+		assert.True(strings.Index(buf.String(), "Repair the host") < strings.Index(buf.String(), "Find the host"))
+		assert.NotContains(buf.String(), "# Fix the host")
+		assert.Equal([]string{"root.fixHost", "root.findHost"}, []string{
+			pcd.LastRunReport().Steps[0].Name,
+			pcd.LastRunReport().Steps[1].Name,
+		})
+		assert.Equal(map[string]string{"HostName": "web-1"}, pcd.LastRunValues())
+	}
+}
@@ -2,7 +2,7 @@ package donothing
 
 // An InputDef specifies a value that a step can receive.
 type InputDef struct {
-	// The type for values of the input. Either "string" or "int"
+	// The type for values of the input. One of "string", "stringlist", or "int"
 	ValueType string
 
 	// The input's name.
@@ -23,3 +23,18 @@ func NewInputDef(valueType string, name string, required bool) InputDef {
 		Required:  required,
 	}
 }
+
+// GetName returns the input's name.
+func (def InputDef) GetName() string {
+	return def.Name
+}
+
+// GetValueType returns the type for values of the input.
+func (def InputDef) GetValueType() string {
+	return def.ValueType
+}
+
+// IsRequired returns whether the input is required by the step.
+func (def InputDef) IsRequired() bool {
+	return def.Required
+}
@@ -0,0 +1,23 @@
+package donothing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// InputDef's accessors should reflect the values passed to NewInputDef.
+func TestInputDef_Accessors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	required := NewInputDef("string", "foo", true)
+	assert.Equal("foo", required.GetName())
+	assert.Equal("string", required.GetValueType())
+	assert.True(required.IsRequired())
+
+	optional := NewInputDef("int", "bar", false)
+	assert.Equal("bar", optional.GetName())
+	assert.Equal("int", optional.GetValueType())
+	assert.False(optional.IsRequired())
+}
@@ -0,0 +1,106 @@
+package donothing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// markdownHeader matches a Markdown header line, capturing the header prefix and the header text.
+var markdownHeader = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*$`)
+
+// LintMarkdown renders the procedure's Markdown and checks the result for structural problems.
+//
+// Whereas Check validates the procedure itself, LintMarkdown validates the document produced by
+// Render, including any Markdown in long descriptions. It returns a list of findings, which is
+// empty if the document looks fine. The following are flagged:
+//
+//   - Headers that skip levels, such as a level 3 header directly after a level 1 header
+//   - Headers with the same anchor as an earlier header, since links to them are ambiguous
+//   - Sections with no content and no subsections
+//
+// Headers in fenced code blocks are ignored. If the procedure can't be rendered, the only finding
+// is the reason why.
+func (pcd *Procedure) LintMarkdown() []string {
+	var b strings.Builder
+	if err := pcd.Render(&b); err != nil {
+		return []string{fmt.Sprintf("Failed to render procedure: %s", err.Error())}
+	}
+	return lintMarkdown(b.String())
+}
+
+// lintMarkdown checks the Markdown document md for the problems described in LintMarkdown.
+func lintMarkdown(md string) []string {
+	findings := make([]string, 0)
+
+	// The header that starts the current section, if any.
+	type header struct {
+		LineNum int
+		Level   int
+		Text    string
+	}
+	var current *header
+	// Whether the current section has any content of its own.
+	hasContent := false
+	// The line number of the first header with each anchor.
+	anchors := make(map[string]int)
+	prevLevel := 0
+	inFence := false
+
+	endSection := func(nextLevel int) {
+		if current != nil && !hasContent && nextLevel <= current.Level {
+			findings = append(findings, fmt.Sprintf(
+				"Line %d: section '%s' is empty",
+				current.LineNum,
+				current.Text,
+			))
+		}
+	}
+
+	for i, line := range strings.Split(md, "\n") {
+		lineNum := i + 1
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			hasContent = true
+			continue
+		}
+		m := markdownHeader.FindStringSubmatch(line)
+		if inFence || m == nil {
+			if strings.TrimSpace(line) != "" {
+				hasContent = true
+			}
+			continue
+		}
+
+		level := len(m[1])
+		endSection(level)
+		if prevLevel > 0 && level > prevLevel+1 {
+			findings = append(findings, fmt.Sprintf(
+				"Line %d: header '%s' skips from level %d to level %d",
+				lineNum,
+				m[2],
+				prevLevel,
+				level,
+			))
+		}
+		anchor := headerAnchor(line)
+		if first, ok := anchors[anchor]; ok {
+			findings = append(findings, fmt.Sprintf(
+				"Line %d: header '%s' has the same anchor (%s) as the header on line %d",
+				lineNum,
+				m[2],
+				anchor,
+				first,
+			))
+		} else {
+			anchors[anchor] = lineNum
+		}
+
+		current = &header{LineNum: lineNum, Level: level, Text: m[2]}
+		hasContent = false
+		prevLevel = level
+	}
+	endSection(0)
+
+	return findings
+}
@@ -0,0 +1,93 @@
+package donothing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lintMarkdown should report skipped header levels, duplicate anchors, and empty sections.
+func TestLintMarkdown(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		In  string
+		Out []string
+	}
+
+	testCases := []testCase{
+		// No problems
+		testCase{
+			In:  "# Title\n\nIntro\n\n## Section\n\nBody\n\n### Subsection\n\nMore\n\n## Another\n\nBody\n",
+			Out: []string{},
+		},
+		// Skipped level
+		testCase{
+			In:  "# Title\n\nIntro\n\n### Subsection\n\nBody\n",
+			Out: []string{"Line 5: header 'Subsection' skips from level 1 to level 3"},
+		},
+		// Going back up several levels is fine
+		testCase{
+			In:  "# Title\n\nIntro\n\n## A\n\nBody\n\n### B\n\nBody\n\n# Other\n\nBody\n",
+			Out: []string{},
+		},
+		// Duplicate anchors
+		testCase{
+			In: "# Title\n\nIntro\n\n## Details\n\nBody\n\n## Details!\n\nBody\n",
+			Out: []string{
+				"Line 9: header 'Details!' has the same anchor (#details) as the header on line 5",
+			},
+		},
+		// Empty sections, both before a sibling and at the end of the document, but not a section
+		// that only has subsections
+		testCase{
+			In: "# Title\n\n## Parent\n\n### Empty\n\n### Full\n\nBody\n\n## Last\n\n",
+			Out: []string{
+				"Line 5: section 'Empty' is empty",
+				"Line 11: section 'Last' is empty",
+			},
+		},
+		// Headers in code blocks are ignored
+		testCase{
+			In:  "# Title\n\nIntro\n\n```\n### Not a header\n```\n",
+			Out: []string{},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert.Equal(tc.Out, lintMarkdown(tc.In))
+	}
+}
+
+// LintMarkdown should flag a header in a long description that skips a level.
+func TestProcedure_LintMarkdown(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.Long("Drain traffic from the load balancer.")
+	})
+	assert.Equal([]string{}, pcd.LintMarkdown())
+
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+		step.Long(`
+			Restart the server.
+
+			#### Troubleshooting
+
+			If the server doesn't come back, page the on-call engineer.
+		`)
+	})
+	findings := pcd.LintMarkdown()
+	if assert.Equal(1, len(findings)) {
+		assert.Regexp(`^Line \d+: header 'Troubleshooting' skips from level 2 to level 4$`, findings[0])
+	}
+}
@@ -2,7 +2,7 @@ package donothing
 
 // An OutputDef specifies a value that a step outputs for later consumption by another step.
 type OutputDef struct {
-	// The type for values of the output. Either "string" or "int"
+	// The type for values of the output. One of "string", "stringlist", or "int"
 	ValueType string
 
 	// The output's name, which another step can refer to in an InputDef if it wants to use this
@@ -14,6 +14,24 @@ type OutputDef struct {
 	// This will be used in the procedure's rendered documentation, and also as part of the prompt
 	// during Procedure.Execute() if the output needs to be provided by the user.
 	Short string
+
+	// Whether the output's value is secret.
+	//
+	// Secret values are not shown to the user when they're used as inputs, and they're redacted
+	// from the run report.
+	Secret bool
+
+	// Whether the output may be left empty.
+	//
+	// During Procedure.Execute(), the user may enter an empty value for an optional output instead
+	// of being prompted again.
+	Optional bool
+
+	// An example value for the output.
+	//
+	// If not empty, the example is shown alongside the output in the procedure's rendered
+	// documentation, and in the prompt during Procedure.Execute().
+	Example string
 }
 
 func NewOutputDef(valueType string, name, short string) OutputDef {
@@ -0,0 +1,218 @@
+package donothing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ExecuteParallel runs through the procedure, executing independent automated subtrees
+// concurrently.
+//
+// Each child of the root step, together with its descendants, is a subtree. A subtree is automated
+// if every step in it is automated (see Step.Automate). Subtrees are started in procedure order,
+// but an automated subtree doesn't wait for earlier subtrees to finish unless it has an input that
+// one of them produces. A subtree containing any manual step requires the user's attention, so it
+// waits for all earlier subtrees to finish, and no later subtree starts until it's done. At most
+// maxConcurrency automated subtrees run at once. An automated subtree that might need to read
+// input from the user, because it branches or has an input that no earlier step produces, is
+// treated like a manual one, so that two subtrees never read input at the same time.
+//
+// The root step is shown to the user but not prompted for, since it only introduces the procedure.
+//
+// If any subtrees fail, the subtrees that depend on them are not executed, and ExecuteParallel
+// returns an error describing every failure once all other subtrees have finished.
+func (pcd *Procedure) ExecuteParallel(maxConcurrency int) error {
+	if maxConcurrency < 1 {
+		return fmt.Errorf("maxConcurrency must be at least 1; got %d", maxConcurrency)
+	}
+	if _, err := pcd.Check(); err != nil {
+		return err
+	}
+
+	tpl, err := ExecTemplate()
+	if err != nil {
+		return err
+	}
+
+	// Output from concurrent subtrees is interleaved, so make sure individual writes don't collide.
+	stdout := pcd.stdout
+	pcd.stdout = &syncWriter{w: stdout}
+	defer func() { pcd.stdout = stdout }()
+
+	ctx := context.Background()
+	pcd.startRun(ctx)
+	if err := pcd.confirmPrerequisites(); err != nil {
+		pcd.finishReport(err)
+		pcd.writeFinalStatus(err, false)
+		return err
+	}
+	tplData := NewStepTemplateData(pcd.rootStep, nil, false)
+	if err := pcd.expandTemplateData(&tplData); err != nil {
+		pcd.finishReport(err)
+		pcd.writeFinalStatus(err, false)
+		return err
+	}
+	tplData.Body = wrapText(tplData.Body, pcd.wrapWidth)
+	var b strings.Builder
+	if err := tpl.Execute(&b, tplData); err != nil {
+		pcd.finishReport(err)
+		pcd.writeFinalStatus(err, false)
+		return err
+	}
+	fmt.Fprintf(pcd.stdout, "%s\n\n", pcd.replaceStandins(b.String()))
+
+	children := pcd.rootStep.GetChildren()
+	done := make([]chan struct{}, len(children))
+	failed := make([]bool, len(children))
+	failures := make([]string, 0)
+	var mu sync.Mutex
+	fail := func(i int, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed[i] = true
+		failures = append(failures, msg)
+	}
+	hasFailed := func(i int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed[i]
+	}
+
+	// Maps each output name to the index of the subtree that produces it.
+	producers := make(map[string]int)
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, child := range children {
+		done[i] = make(chan struct{})
+
+		// The earlier subtrees on which this one depends, in procedure order.
+		deps := make([]int, 0)
+		seen := make(map[int]bool)
+		child.Walk(func(step *Step) error {
+			for _, inputDef := range step.GetInputDefs() {
+				if j, ok := producers[inputDef.Name]; ok && !seen[j] {
+					seen[j] = true
+					deps = append(deps, j)
+				}
+			}
+			return nil
+		})
+
+		// limit says whether the subtree counts against maxConcurrency. The slot is taken only
+		// once the subtree's dependencies have finished, so that a subtree waiting on a producer
+		// never holds a slot the producer needs.
+		run := func(i int, child *Step, deps []int, limit bool) {
+			defer close(done[i])
+			for _, j := range deps {
+				<-done[j]
+				if hasFailed(j) {
+					fail(i, fmt.Sprintf("Step '%s' was not executed because step '%s' failed", child.AbsoluteName(), children[j].AbsoluteName()))
+					return
+				}
+			}
+			if limit {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			var state runState
+			if err := pcd.executeTree(ctx, child, tpl, &state); err != nil {
+				fail(i, err.Error())
+			}
+		}
+
+		if isAutomatedTree(child) && !needsUser(child, producers) {
+			wg.Add(1)
+			go func(i int, child *Step, deps []int) {
+				defer wg.Done()
+				run(i, child, deps, true)
+			}(i, child, deps)
+		} else {
+			wg.Wait()
+			run(i, child, deps, false)
+		}
+
+		child.Walk(func(step *Step) error {
+			for _, outputDef := range step.GetOutputDefs() {
+				producers[outputDef.Name] = i
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		err := fmt.Errorf("%d failure(s) during parallel execution: %s", len(failures), strings.Join(failures, "; "))
+		pcd.finishReport(err)
+		pcd.writeFinalStatus(err, false)
+		return err
+	}
+	pcd.finishReport(nil)
+	pcd.writeFinalStatus(nil, false)
+	fmt.Fprintln(pcd.stdout, "Done.")
+	return nil
+}
+
+// isAutomatedTree returns whether step and all its descendants are automated.
+func isAutomatedTree(step *Step) bool {
+	automated := true
+	step.Walk(func(s *Step) error {
+		if !s.IsAutomated() {
+			automated = false
+		}
+		return nil
+	})
+	return automated
+}
+
+// needsUser returns whether executing the automated subtree rooted at step might read input from
+// the user.
+//
+// That's the case if some step in the subtree branches, since the user is asked which way to go,
+// or if some input of the subtree, or list that a step in it repeats for, isn't sure to have a
+// value by the time it's needed. produced maps the names of the outputs produced by earlier
+// subtrees to those subtrees. Outputs produced earlier in the subtree itself count as well, except
+// optional ones, since an automated step needn't set them.
+func needsUser(step *Step, produced map[string]int) bool {
+	ownOutputs := make(map[string]bool)
+	needs := false
+	step.Walk(func(s *Step) error {
+		if s.GetBranch() != nil {
+			needs = true
+		}
+		names := make([]string, 0)
+		for _, inputDef := range s.GetInputDefs() {
+			names = append(names, inputDef.Name)
+		}
+		if s.repeatFor != "" {
+			names = append(names, s.repeatFor)
+		}
+		for _, name := range names {
+			if _, ok := produced[name]; !ok && !ownOutputs[name] {
+				needs = true
+			}
+		}
+		for _, outputDef := range s.GetOutputDefs() {
+			if !outputDef.Optional {
+				ownOutputs[outputDef.Name] = true
+			}
+		}
+		return nil
+	})
+	return needs
+}
+
+// syncWriter is an io.Writer that serializes writes to an underlying writer.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p to the underlying writer.
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
@@ -0,0 +1,259 @@
+package donothing
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ExecuteParallel should run independent automated subtrees concurrently.
+func TestProcedure_ExecuteParallel_Independent(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// Each step waits until both steps have started. If the steps were run one at a time, the first
+	// one would time out.
+	var started sync.WaitGroup
+	started.Add(2)
+	bothStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(bothStarted)
+	}()
+	fn := func(ec *ExecContext) error {
+		started.Done()
+		select {
+		case <-bothStarted:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("timed out waiting for the other step to start")
+		}
+	}
+
+	pcd := NewProcedure()
+	pcd.Short("Independent subtrees")
+	pcd.AddStep(func(step *Step) {
+		step.Name("left")
+		step.Short("Left")
+		step.Automate(fn)
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("right")
+		step.Short("Right")
+		step.Automate(fn)
+	})
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteParallel(2))
+	assert.Contains(stdout.String(), "Done.")
+}
+
+// ExecuteParallel should run a subtree only after the subtrees that produce its inputs.
+func TestProcedure_ExecuteParallel_Dependent(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	events := make([]string, 0)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	pcd := NewProcedure()
+	pcd.Short("Dependent subtrees")
+	pcd.AddStep(func(step *Step) {
+		step.Name("producer")
+		step.Short("Produce")
+		step.OutputString("Value", "A value")
+		step.Automate(func(ec *ExecContext) error {
+			record("producer start")
+			time.Sleep(50 * time.Millisecond)
+			ec.SetOutput("Value", "42")
+			record("producer end")
+			return nil
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("consumer")
+		step.Short("Consume")
+		step.InputString("Value", true)
+		step.Automate(func(ec *ExecContext) error {
+			record("consumer start " + ec.GetInput("Value"))
+			return nil
+		})
+	})
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteParallel(2))
+	assert.Equal([]string{"producer start", "producer end", "consumer start 42"}, events)
+}
+
+// ExecuteParallel should report every failure, and skip subtrees that depend on a failed one.
+func TestProcedure_ExecuteParallel_Errors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	consumerRan := false
+	pcd := NewProcedure()
+	pcd.Short("Failing subtrees")
+	pcd.AddStep(func(step *Step) {
+		step.Name("producer")
+		step.Short("Produce")
+		step.OutputString("Value", "A value")
+		step.Automate(func(ec *ExecContext) error {
+			return errors.New("producer broke")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("independent")
+		step.Short("Independent")
+		step.Automate(func(ec *ExecContext) error {
+			return errors.New("independent broke")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("consumer")
+		step.Short("Consume")
+		step.InputString("Value", true)
+		step.Automate(func(ec *ExecContext) error {
+			consumerRan = true
+			return nil
+		})
+	})
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	err := pcd.ExecuteParallel(3)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "3 failure(s)")
+		assert.Contains(err.Error(), "producer broke")
+		assert.Contains(err.Error(), "independent broke")
+		assert.Contains(err.Error(), "Step 'root.consumer' was not executed because step 'root.producer' failed")
+	}
+	assert.False(consumerRan)
+	assert.NotNil(pcd.ExecuteParallel(0))
+}
+
+// ExecuteParallel should run a manual subtree only once all earlier subtrees have finished.
+func TestProcedure_ExecuteParallel_Manual(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	automatedDone := false
+	pcd := NewProcedure()
+	pcd.Short("Mixed subtrees")
+	pcd.AddStep(func(step *Step) {
+		step.Name("automated")
+		step.Short("Automated")
+		step.Automate(func(ec *ExecContext) error {
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			automatedDone = true
+			return nil
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("manual")
+		step.Short("Manual")
+	})
+
+	pcd.stdin = strings.NewReader("\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteParallel(2))
+	mu.Lock()
+	assert.True(automatedDone)
+	mu.Unlock()
+	out := stdout.String()
+	assert.Less(strings.Index(out, "Running automated step 'root.automated'"), strings.Index(out, "## (1) Manual"))
+	assert.Equal(1, strings.Count(out, "[Enter] to proceed"))
+}
+
+// With a maxConcurrency of 1, subtrees waiting on a producer shouldn't keep the producer from
+// running.
+func TestProcedure_ExecuteParallel_SingleSlot(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Dependency chain")
+	pcd.AddStep(func(step *Step) {
+		step.Name("producer")
+		step.Short("Produce")
+		step.OutputString("Value", "A value")
+		step.Automate(func(ec *ExecContext) error {
+			time.Sleep(20 * time.Millisecond)
+			ec.SetOutput("Value", "42")
+			return nil
+		})
+	})
+	for _, name := range []string{"consumer1", "consumer2", "consumer3"} {
+		name := name
+		pcd.AddStep(func(step *Step) {
+			step.Name(name)
+			step.Short("Consume")
+			step.InputString("Value", true)
+			step.Automate(func(ec *ExecContext) error { return nil })
+		})
+	}
+	pcd.stdout = &bytes.Buffer{}
+
+	done := make(chan error, 1)
+	go func() { done <- pcd.ExecuteParallel(1) }()
+	select {
+	case err := <-done:
+		assert.Nil(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExecuteParallel(1) deadlocked")
+	}
+}
+
+// An automated subtree that has to prompt the user for an input should run on its own, like a
+// manual one, so that prompts don't interleave.
+func TestProcedure_ExecuteParallel_Prompting(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Prompting subtrees")
+	// The root step isn't prompted for during ExecuteParallel, so its output is asked for by the
+	// first step that uses it.
+	pcd.rootStep.OutputString("Region", "The region")
+	var mu sync.Mutex
+	got := make([]string, 0)
+	for _, name := range []string{"first", "second"} {
+		name := name
+		pcd.AddStep(func(step *Step) {
+			step.Name(name)
+			step.Short("Use the region")
+			step.InputString("Region", true)
+			step.Automate(func(ec *ExecContext) error {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, name+" "+ec.GetInput("Region"))
+				return nil
+			})
+		})
+	}
+	assert.True(needsUser(pcd.rootStep.children[0], map[string]int{}))
+	assert.False(needsUser(pcd.rootStep.children[0], map[string]int{"Region": 0}))
+
+	pcd.stdin = strings.NewReader("us-east-1\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.ExecuteParallel(2))
+	assert.Equal(1, strings.Count(stdout.String(), "Value for input 'Region'"))
+	assert.Equal([]string{"first us-east-1", "second us-east-1"}, got)
+}
@@ -3,20 +3,146 @@ package donothing
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
+// DefaultMaxDepth is the deepest that a step may be nested, unless the limit is changed with
+// SetMaxDepth.
+const DefaultMaxDepth = 1000
+
 // A Procedure is a sequence of Steps that can be executed or rendered to markdown.
 type Procedure struct {
 	// The root step of the procedure, of which all other steps are descendants.
 	rootStep *Step
 
+	// The number of levels by which to shift headers down in rendered Markdown.
+	headingOffset int
+	// Whether to collapse chains of single-child steps in rendered Markdown.
+	collapseChains bool
+	// Whether to precede each section header in rendered Markdown with a comment naming the step.
+	stepComments bool
+	// Whether to include a table of all outputs in rendered Markdown. See SetVariablesTable.
+	variablesTable bool
+	// How step inputs are rendered in Markdown. See SetInputStyle.
+	inputStyle InputStyle
+	// Whether to include a glossary of outputs in rendered Markdown. See SetGlossary.
+	glossary bool
+	// Whether Execute prompts with a numbered menu instead of a command line. See SetMenuPrompt.
+	menuPrompt bool
+	// Whether steps skipped on the way to a skipto target are summarized. See SetQuietSkips.
+	quietSkips bool
+	// Whether the user is asked to confirm each value they enter. See SetConfirmInputs.
+	confirmInputs bool
+	// The column width at which step bodies are wrapped during Execute. 0 means no wrapping.
+	wrapWidth int
+	// The deepest that a step may be nested, or 0 for DefaultMaxDepth. See SetMaxDepth.
+	maxDepth int
+	// Whether only backtick standins that look like code spans are replaced. See
+	// SetStrictStandins.
+	strictStandins bool
+	// Whether ExecuteContext traps SIGINT. See SetInterruptHandling.
+	handleInterrupt bool
+	// The path to which progress is written if execution is interrupted, or "" for none. See
+	// SetStateFile.
+	stateFile string
+	// The variables that can be substituted into step descriptions, or nil if SetVars hasn't been
+	// called.
+	vars map[string]string
+	// Conditions the user must confirm before execution starts. See Prerequisite.
+	prerequisites []string
+	// Whether to capture the environment in the run report, and which environment variables to
+	// capture. See CaptureEnv.
+	captureEnv bool
+	envVars    []string
+
+	// The values of outputs collected so far during execution, keyed by output name.
+	values map[string]string
+	// The report for the most recent execution.
+	report *RunReport
+	// Guards values and report, which may be accessed concurrently during ExecuteParallel.
+	mu sync.Mutex
+
 	stdin  io.Reader
 	stdout io.Writer
+	// The writer to which a status line is written at each step transition, or nil for none. See
+	// SetStatusWriter.
+	statusWriter io.Writer
+	// The source of answers that are read before stdin, or nil if there is none. See SetAnswers.
+	answersIn io.Reader
+	// Reader of lines from stdin. It's created at the start of the first execution that reads from
+	// the current stdin and kept across executions, so that a read cut short by the end of one
+	// execution hands its line to the next.
+	in *lineReader
+	// Reader of lines from answersIn, kept across executions in the same way as in.
+	answers *lineReader
+	// Whether any answers remain to be read in the current execution.
+	answersLeft bool
+	// The context of the current execution, which cuts short any wait for user input when it's
+	// done.
+	runCtx context.Context
+	// Every line of user input read so far, or nil if input isn't being recorded. See
+	// ExecuteWithBaseline.
+	responses *[]response
+	// Whether the user is currently being prompted for the value of a secret output, so that
+	// what they enter must not be shown.
+	secretPrompt bool
+}
+
+// A response is a line of user input, as recorded during ExecuteWithBaseline.
+type response struct {
+	Entry string
+	// Whether the line was the value of a secret output
+	Secret bool
+}
+
+// ErrInterrupted is returned (wrapped) by ExecuteContext when execution is interrupted, either by
+// cancellation of its context or, if SetInterruptHandling is enabled, by SIGINT.
+var ErrInterrupted = errors.New("execution interrupted")
+
+// ErrStepNotFound is returned (wrapped) by GetStepByName, and by the methods that look up steps
+// with it, when there's no step with the given name.
+var ErrStepNotFound = errors.New("step not found")
+
+// ErrCheckFailed is returned (wrapped in a *CheckError) by Check, and by the methods that check the
+// procedure before rendering or executing it, when problems are found in the procedure.
+var ErrCheckFailed = errors.New("check failed")
+
+// ErrAborted is returned (wrapped) by Execute and the like when execution is abandoned before it
+// finishes, either because the user chose to quit or because a prerequisite wasn't met.
+var ErrAborted = errors.New("execution aborted")
+
+// A CheckError is the error returned when problems are found in a procedure. It wraps
+// ErrCheckFailed.
+//
+// Use errors.As to get at the problems from the error returned by RenderStep, ExecuteStep, and the
+// like.
+type CheckError struct {
+	// The problems that were found, as returned by Check
+	Problems []string
+}
+
+// Error returns the same message for any number of problems; the problems themselves are in
+// Problems.
+func (e *CheckError) Error() string {
+	return "Problems were found in the procedure"
+}
+
+// Unwrap returns ErrCheckFailed, so that errors.Is(err, ErrCheckFailed) holds for a CheckError.
+func (e *CheckError) Unwrap() error {
+	return ErrCheckFailed
 }
 
 // Short provides the procedure with a short description.
@@ -43,6 +169,154 @@ func (pcd *Procedure) Long(s string) {
 	pcd.rootStep.Long(s)
 }
 
+// SetRootName renames the procedure's root step.
+//
+// By default, the root step is named "root". Since the root step's name is the first part of every
+// step's absolute name, it appears in the names that are passed to DefaultCLI and GetStepByName and
+// that are shown in the procedure's documentation. For example, after SetRootName("deploy"), the
+// step formerly known as "root.build" is named "deploy.build".
+//
+// name must be non-empty and must not contain dots or whitespace; otherwise Check will fail.
+func (pcd *Procedure) SetRootName(name string) {
+	pcd.rootStep.Name(name)
+}
+
+// SetCollapseSingleChildChains sets whether chains of single-child steps are collapsed in the
+// procedure's rendered Markdown.
+//
+// When enabled, a step whose only child itself has only one child is rendered, along with those
+// descendants, as a single section titled like "Parent / Child / Grandchild". Collapsing continues
+// down the chain for as long as each step has exactly one child. Section numbers, anchors, and
+// the table of contents reflect the collapsed structure. Execution is unaffected.
+func (pcd *Procedure) SetCollapseSingleChildChains(collapse bool) {
+	pcd.collapseChains = collapse
+}
+
+// SetMenuPrompt sets whether Execute prompts for the next action with a numbered menu.
+//
+// By default, the user is prompted to press Enter to proceed or to type a command such as "skip".
+// With the menu prompt, the user is instead shown a numbered list of options and enters the number
+// of the one they want, or just presses Enter to proceed. Choosing to quit stops execution with an
+// error wrapping ErrAborted.
+func (pcd *Procedure) SetMenuPrompt(enabled bool) {
+	pcd.menuPrompt = enabled
+}
+
+// SetConfirmInputs sets whether Execute asks the user to confirm each value they enter.
+//
+// When enabled, after the user enters the value of an input or output, they're shown the value and
+// asked whether it's correct. If they answer no, they're prompted for the value again, so that a
+// typo is caught before the value is used by later steps. The value of a secret output isn't
+// shown; the user is just asked to confirm that they entered it correctly.
+func (pcd *Procedure) SetConfirmInputs(confirm bool) {
+	pcd.confirmInputs = confirm
+}
+
+// SetQuietSkips sets whether Execute stays quiet about each step it skips on the way to a skipto
+// target.
+//
+// By default, when the user skips to a step, a "Skipping step" line is printed for every step
+// between the current step and the target. With quiet skips enabled, those lines are replaced by a
+// single "Skipped N steps" line once the target is reached, or once execution ends if the target
+// is never reached. Skipped steps are recorded in the run report either way.
+func (pcd *Procedure) SetQuietSkips(quiet bool) {
+	pcd.quietSkips = quiet
+}
+
+// SetStepComments sets whether each section header in rendered Markdown is preceded by an HTML
+// comment giving the step's absolute name, like so:
+//
+//     <!-- step: root.restart.drain -->
+//     ### (1.0) Drain traffic
+//
+// The comments don't show up when the Markdown is displayed, but they let tooling map sections
+// back to the steps they came from.
+func (pcd *Procedure) SetStepComments(enabled bool) {
+	pcd.stepComments = enabled
+}
+
+// SetVariablesTable sets whether the procedure's rendered Markdown includes a "Variables" table.
+//
+// The table lists every output produced by the rendered steps, along with its type, the step that
+// produces it, and the steps that take it as an input, with links to each step's section. It's
+// placed in the opening section of the document, before the table of contents.
+func (pcd *Procedure) SetVariablesTable(enabled bool) {
+	pcd.variablesTable = enabled
+}
+
+// SetGlossary sets whether the procedure's rendered Markdown ends with a glossary of outputs.
+//
+// The glossary has an entry for every output produced by the rendered steps, giving its
+// description and type, the step that produces it, and the steps that take it as an input. Each
+// input and output listed in a step's section links to the glossary entry for its value.
+func (pcd *Procedure) SetGlossary(enabled bool) {
+	pcd.glossary = enabled
+}
+
+// InputStyle is a way of rendering a step's inputs in Markdown. See SetInputStyle.
+type InputStyle int
+
+const (
+	// InputStyleBullets renders a step's inputs as a bulleted list of names.
+	InputStyleBullets InputStyle = iota
+	// InputStyleTable renders a step's inputs as a table giving each input's name, type, whether
+	// it's required, and the description of the output it refers to.
+	InputStyleTable
+)
+
+// SetInputStyle sets how step inputs are rendered in the procedure's Markdown.
+//
+// The default is InputStyleBullets. InputStyleTable is clearer for steps with many inputs.
+func (pcd *Procedure) SetInputStyle(style InputStyle) {
+	pcd.inputStyle = style
+}
+
+// SetWrapWidth sets the column width at which step bodies are word-wrapped during Execute and in
+// the output of RenderStepText.
+//
+// Lines are wrapped individually, so blank lines are preserved, and indented lines (such as those
+// in a code block) are never wrapped. Markdown rendering is unaffected, since Markdown viewers do
+// their own wrapping.
+//
+// If cols is 0 (the default), no wrapping is performed.
+func (pcd *Procedure) SetWrapWidth(cols int) {
+	pcd.wrapWidth = cols
+}
+
+// SetMaxDepth sets the deepest that a step may be nested.
+//
+// A step's depth is its number of ancestors, as returned by Step.Depth. Check reports any step
+// nested deeper than depth, which keeps a pathologically deep procedure from exhausting the stack
+// when it's rendered or executed. If depth is 0, DefaultMaxDepth is used.
+func (pcd *Procedure) SetMaxDepth(depth int) {
+	pcd.maxDepth = depth
+}
+
+// SetStrictStandins sets whether only backtick standins that look like code spans are replaced with
+// backticks.
+//
+// By default, every backtick standin ("@@") is replaced with a backtick when the procedure is
+// rendered or executed, whether or not it has a partner. This mangles any "@@" that's meant
+// literally, as in "user@@host". With strict standins enabled, standins are paired up from left to
+// right, and a pair is only replaced if the first standin is followed by a non-whitespace
+// character, the second is preceded by one, and both are on the same line. Standins in any other
+// pair, or with no partner, are kept literal.
+//
+// In either mode, a standin can be escaped with a backslash, as in "\@@", to keep it literal.
+func (pcd *Procedure) SetStrictStandins(strict bool) {
+	pcd.strictStandins = strict
+}
+
+// SetHeadingOffset shifts all headers in the procedure's rendered Markdown down by n levels.
+//
+// This is useful when embedding the procedure's documentation under a section of a larger
+// document. For example, with an offset of 1, the procedure's title is rendered as a "##" header
+// instead of a "#" header. Headers are never shifted below "######". A negative n shifts headers up
+// instead, but never above "#".
+func (pcd *Procedure) SetHeadingOffset(n int) {
+	pcd.headingOffset = n
+}
+
 // AddStep adds a step to the procedure.
 //
 // A new Step will be instantiated and passed to fn to be defined.
@@ -50,8 +324,24 @@ func (pcd *Procedure) AddStep(fn func(*Step)) {
 	pcd.rootStep.AddStep(fn)
 }
 
+// AddProcedure adds the given procedure to the procedure as a step.
+//
+// See Step.AddProcedure for details.
+func (pcd *Procedure) AddProcedure(name string, sub *Procedure) error {
+	return pcd.rootStep.AddProcedure(name, sub)
+}
+
 // GetStepByName returns the step with the given (absolute) name.
+//
+// If there's no such step, the error returned wraps ErrStepNotFound.
+//
+// Since RenderStep, ExecuteStep, and the like all look up their steps with GetStepByName, an empty
+// or whitespace-only name gets an error that points the caller to the root step's name instead.
 func (pcd *Procedure) GetStepByName(stepName string) (*Step, error) {
+	if strings.TrimSpace(stepName) == "" {
+		return nil, fmt.Errorf("Step name must not be empty; use '%s' for the whole procedure", pcd.rootStep.AbsoluteName())
+	}
+
 	var foundStep *Step
 	err := pcd.rootStep.Walk(func(step *Step) error {
 		absNmae := step.AbsoluteName()
@@ -71,47 +361,74 @@ func (pcd *Procedure) GetStepByName(stepName string) (*Step, error) {
 	if err != nil {
 		return nil, err
 	}
-	return nil, fmt.Errorf("No step with name '%s'", stepName)
+	return nil, fmt.Errorf("No step with name '%s': %w", stepName, ErrStepNotFound)
 }
 
 // Check validates that the procedure makes sense.
 //
-// If problems are found, it returns the list of problems along with an error.
+// If problems are found, it returns the list of problems along with a *CheckError, which wraps
+// ErrCheckFailed.
 //
 // It checks the procedure against the following expectations:
 //
-//   1. Every step has a unique absolute name with no empty parts.
-//   2. Every step has a short description
+//   1. Every step has a unique absolute name with no empty parts, and the root step's name
+//      contains no dots or whitespace. Duplicate names can be fixed with DeduplicateNames.
+//   2. Every step has a short description, and every input and output has a supported type.
 //   3. Every input has a name that matches the name of an output from a previous step.
+//   4. Every reference has a name that matches the name of an output from any step.
+//   5. All inputs that refer to the same output agree on whether it's required.
+//   6. No required input refers to an output whose producing step might not execute. A step that
+//      repeats for each item in a list might not execute, since the list might be empty. So might
+//      any of its descendants, except from the point of view of other steps in the same repetition.
+//   7. Every variable referred to in a step's description has been set with SetVars.
+//   8. No step takes as an input an output produced by one of its own descendants, since a step
+//      executes before its descendants do.
+//   9. Every branch names two different children of its step.
+//  10. No required input refers to an optional output, since the output's value may be empty.
+//  11. No step is nested deeper than the maximum depth set with SetMaxDepth.
+//
+// If some steps have inputs but no step has any outputs, the author has most likely forgotten to
+// declare outputs. In that case, the first problem returned says so, ahead of the problems with
+// the individual inputs.
 func (pcd *Procedure) Check() ([]string, error) {
 	steps := make(map[string]*Step)
 	outputs := make(map[string]OutputDef)
 	problems := make([]string, 0)
 
-	err := pcd.rootStep.Walk(func(step *Step) error {
-		absName := step.AbsoluteName()
-		if absName[len(absName)-1:] == "." {
-			if step.parent == nil {
-				// I really hope this never happens. The root step should get its name from
-				// donothing, not from the calling code.
-				problems = append(problems, "Root step does not have name")
-			} else {
-				problems = append(problems, fmt.Sprintf("Child step of '%s' does not have name", step.parent.AbsoluteName()))
-			}
-		}
+	// The step that produces each output, keyed by output name.
+	producers := make(map[string]*Step)
+	// The first input seen for each output name, and the step it belongs to.
+	type inputUse struct {
+		StepName string
+		Required bool
+	}
+	firstUses := make(map[string]inputUse)
 
-		if steps[absName] != nil {
-			problems = append(problems, fmt.Sprintf("More than one step with name '%s'", absName))
+	allOutputs := make(map[string]bool)
+	pcd.rootStep.Walk(func(step *Step) error {
+		for _, outputDef := range step.GetOutputDefs() {
+			allOutputs[outputDef.Name] = true
 		}
-		steps[step.AbsoluteName()] = step
+		return nil
+	})
 
-		if step.GetShort() == "" {
-			problems = append(problems, fmt.Sprintf("Step '%s' has no Short value", absName))
-		}
+	err := pcd.rootStep.Walk(func(step *Step) error {
+		absName := step.AbsoluteName()
+		problems = append(problems, step.checkStructure(steps)...)
+		steps[absName] = step
 
 		for _, inputDef := range step.GetInputDefs() {
 			matchingOutputDef, ok := outputs[inputDef.Name]
 			if !ok {
+				if producer := descendantProducer(step, inputDef.Name); producer != nil {
+					problems = append(problems, fmt.Sprintf(
+						"Input '%s' of step '%s' is produced by its descendant '%s', which executes after it",
+						inputDef.Name,
+						absName,
+						producer.AbsoluteName(),
+					))
+					continue
+				}
 				problems = append(problems, fmt.Sprintf(
 					"Input '%s' of step '%s' does not refer to an output from any previous step",
 					inputDef.Name,
@@ -129,10 +446,77 @@ func (pcd *Procedure) Check() ([]string, error) {
 					matchingOutputDef.ValueType,
 				))
 			}
+
+			if use, ok := firstUses[inputDef.Name]; !ok {
+				firstUses[inputDef.Name] = inputUse{StepName: absName, Required: inputDef.Required}
+			} else if use.Required != inputDef.Required {
+				requiredBy, optionalFor := use.StepName, absName
+				if inputDef.Required {
+					requiredBy, optionalFor = absName, use.StepName
+				}
+				problems = append(problems, fmt.Sprintf(
+					"Input '%s' is required by step '%s' but optional for step '%s'",
+					inputDef.Name,
+					requiredBy,
+					optionalFor,
+				))
+			}
+
+			if inputDef.Required && matchingOutputDef.Optional {
+				problems = append(problems, fmt.Sprintf(
+					"Required input '%s' of step '%s' refers to optional output of step '%s', which may be empty",
+					inputDef.Name,
+					absName,
+					producers[inputDef.Name].AbsoluteName(),
+				))
+			}
+
+			if inputDef.Required {
+				if repeater := repeatingAncestor(producers[inputDef.Name]); repeater != nil && !isDescendant(step, repeater) {
+					problems = append(problems, fmt.Sprintf(
+						"Required input '%s' of step '%s' is produced by step '%s', which might not execute because step '%s' repeats for each item in '%s'",
+						inputDef.Name,
+						absName,
+						producers[inputDef.Name].AbsoluteName(),
+						repeater.AbsoluteName(),
+						repeater.repeatFor,
+					))
+				}
+			}
+		}
+
+		if branch := step.GetBranch(); branch != nil {
+			for _, name := range []string{branch.YesStep, branch.NoStep} {
+				if childNamed(step, name) == nil {
+					problems = append(problems, fmt.Sprintf(
+						"Branch of step '%s' refers to '%s', which is not a child of the step",
+						absName,
+						name,
+					))
+				}
+			}
+			if branch.YesStep == branch.NoStep {
+				problems = append(problems, fmt.Sprintf(
+					"Branch of step '%s' has the same step, '%s', for both answers",
+					absName,
+					branch.YesStep,
+				))
+			}
+		}
+
+		for _, name := range step.GetReferences() {
+			if !allOutputs[name] {
+				problems = append(problems, fmt.Sprintf(
+					"Reference '%s' of step '%s' does not refer to an output from any step",
+					name,
+					absName,
+				))
+			}
 		}
 
 		for _, outputDef := range step.GetOutputDefs() {
 			outputs[outputDef.Name] = outputDef
+			producers[outputDef.Name] = step
 		}
 
 		return nil
@@ -141,39 +525,268 @@ func (pcd *Procedure) Check() ([]string, error) {
 		return []string{}, fmt.Errorf("Error while checking procedure: %w", err)
 	}
 
+	pcd.rootStep.Walk(func(step *Step) error {
+		td := NewStepTemplateData(step, nil, false)
+		if err := pcd.expandTemplateData(&td); err != nil {
+			problems = append(problems, err.Error())
+		}
+		return nil
+	})
+
+	if len(allOutputs) == 0 {
+		hasInputs := false
+		pcd.rootStep.Walk(func(step *Step) error {
+			if len(step.GetInputDefs()) > 0 {
+				hasInputs = true
+			}
+			return nil
+		})
+		if hasInputs {
+			problems = append([]string{
+				"Some steps have inputs, but no step has any outputs; did you forget to declare outputs with Step.Output*?",
+			}, problems...)
+		}
+	}
+
+	maxDepth := pcd.maxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	pcd.rootStep.Walk(func(step *Step) error {
+		if step.Depth() > maxDepth {
+			problems = append(problems, fmt.Sprintf(
+				"Step '%s' is nested %d steps deep, deeper than the maximum of %d",
+				step.AbsoluteName(),
+				step.Depth(),
+				maxDepth,
+			))
+			return NoRecurse
+		}
+		return nil
+	})
+
 	if len(problems) > 0 {
-		return problems, errors.New("Problems were found in the procedure")
+		return problems, &CheckError{Problems: problems}
 	}
 	return []string{}, nil
 }
 
+// CheckOrdering validates that every step that consumes an output comes after a step that
+// produces it.
+//
+// For each input whose name matches the name of an output from any step, CheckOrdering checks that
+// some step producing the output precedes the consuming step in walk order. If none does, the
+// steps are out of order, as can happen when steps are sorted or inserted programmatically, and a
+// problem naming both the consumer and the producer is returned. Inputs that don't match any
+// output at all are left for Check to report. If there are no problems, an empty slice is
+// returned.
+func (pcd *Procedure) CheckOrdering() []string {
+	problems := make([]string, 0)
+
+	// The first step in walk order that produces each output, keyed by output name.
+	firstProducers := make(map[string]*Step)
+	pcd.rootStep.Walk(func(step *Step) error {
+		for _, outputDef := range step.GetOutputDefs() {
+			if firstProducers[outputDef.Name] == nil {
+				firstProducers[outputDef.Name] = step
+			}
+		}
+		return nil
+	})
+
+	produced := make(map[string]bool)
+	pcd.rootStep.Walk(func(step *Step) error {
+		for _, inputDef := range step.GetInputDefs() {
+			producer := firstProducers[inputDef.Name]
+			if producer == nil || produced[inputDef.Name] {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf(
+				"Step '%s' consumes output '%s', but the step that produces it, '%s', comes after it",
+				step.AbsoluteName(),
+				inputDef.Name,
+				producer.AbsoluteName(),
+			))
+		}
+		for _, outputDef := range step.GetOutputDefs() {
+			produced[outputDef.Name] = true
+		}
+		return nil
+	})
+
+	return problems
+}
+
+// DeduplicateNames renames steps so that every step has a unique absolute name.
+//
+// Duplicate absolute names can arise when procedures are composed programmatically, for example
+// when the same steps are added twice under one parent. Check reports them as problems;
+// DeduplicateNames fixes them instead. Walking the procedure in execution order, the first step
+// with a given absolute name keeps it, and each later step with the same absolute name has a
+// number appended to its name, starting at 2, so that "restart" becomes "restart2". A number is
+// chosen that doesn't collide with the name of any other step.
+//
+// Since inputs and outputs are matched by name rather than by step, renaming steps doesn't affect
+// them. A branch that refers to a duplicated child name keeps referring to the first child with
+// that name, just as it did before.
+//
+// DeduplicateNames returns a description of each rename, in the order they were made. If no steps
+// needed renaming, an empty slice is returned.
+func (pcd *Procedure) DeduplicateNames() []string {
+	changes := make([]string, 0)
+
+	taken := make(map[string]bool)
+	pcd.rootStep.Walk(func(step *Step) error {
+		taken[step.AbsoluteName()] = true
+		return nil
+	})
+
+	seen := make(map[string]bool)
+	pcd.rootStep.Walk(func(step *Step) error {
+		absName := step.AbsoluteName()
+		if seen[absName] && step.name != "" {
+			name := step.name
+			for n := 2; ; n++ {
+				step.name = fmt.Sprintf("%s%d", name, n)
+				if !taken[step.AbsoluteName()] {
+					break
+				}
+			}
+			changes = append(changes, fmt.Sprintf("Renamed step '%s' to '%s'", absName, step.AbsoluteName()))
+			absName = step.AbsoluteName()
+			taken[absName] = true
+		}
+		seen[absName] = true
+		return nil
+	})
+
+	return changes
+}
+
+// repeatingAncestor returns the closest of step and its ancestors that repeats for each item in a
+// list, or nil if there is none.
+func repeatingAncestor(step *Step) *Step {
+	for s := step; s != nil; s = s.parent {
+		if s.repeatFor != "" {
+			return s
+		}
+	}
+	return nil
+}
+
+// childNamed returns the child of step with the given name, or nil if there is none.
+func childNamed(step *Step, name string) *Step {
+	for _, c := range step.GetChildren() {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// descendantProducer returns the first of step's descendants (not including step itself) that
+// produces the named output, or nil if there is none.
+func descendantProducer(step *Step, name string) *Step {
+	var producer *Step
+	step.Walk(func(s *Step) error {
+		if s == step || producer != nil {
+			return nil
+		}
+		for _, outputDef := range s.GetOutputDefs() {
+			if outputDef.Name == name {
+				producer = s
+			}
+		}
+		return nil
+	})
+	return producer
+}
+
+// isDescendant returns whether step is ancestor itself or one of ancestor's descendants.
+func isDescendant(step *Step, ancestor *Step) bool {
+	for s := step; s != nil; s = s.parent {
+		if s == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
 // Render prints the procedure's Markdown representation to f.
 //
-// Any occurrence of the string "@@" in the executed template output will be replaced with a
-// backtick.
+// Pairs of backtick standins ("@@") in the executed template output will be replaced with
+// backticks. See SetStrictStandins.
 func (pcd *Procedure) Render(f io.Writer) error {
-	return pcd.RenderStep(f, "root")
+	return pcd.RenderStep(f, pcd.rootStep.AbsoluteName())
+}
+
+// RenderStable prints the procedure's Markdown representation to f, like Render, with whitespace
+// normalized so that the output stays stable across template changes.
+//
+// Trailing whitespace is stripped from every line, runs of three or more blank lines are collapsed
+// to two, and the output ends with exactly one newline.
+func (pcd *Procedure) RenderStable(f io.Writer) error {
+	var b strings.Builder
+	if err := pcd.Render(&b); err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "%s", normalizeWhitespace(b.String()))
+	return nil
+}
+
+// normalizeWhitespace normalizes the whitespace in s as described in RenderStable.
+func normalizeWhitespace(s string) string {
+	lines := make([]string, 0)
+	blanks := 0
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			blanks++
+			if blanks > 2 {
+				continue
+			}
+		} else {
+			blanks = 0
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
 }
 
 // RenderStep prints the given step from the procedure as Markdown to f.
 //
-// Any occurrence of the string "@@" in the executed template output will be replaced with a
-// backtick.
+// Pairs of backtick standins ("@@") in the executed template output will be replaced with
+// backticks. See SetStrictStandins.
 func (pcd *Procedure) RenderStep(f io.Writer, stepName string) error {
-	if _, err := pcd.Check(); err != nil {
+	tplData, err := pcd.markupRenderData(stepName)
+	if err != nil {
 		return err
 	}
+	return pcd.renderMarkdown(f, tplData)
+}
 
-	tpl, err := DocTemplate()
+// RenderFiltered prints the procedure's Markdown representation to f, like Render, including only
+// the steps for which keep returns true.
+//
+// This is useful for rendering variants of the documentation, such as one containing only the
+// steps meant for a particular audience. The ancestors of every kept step are kept too, so that
+// the kept step has a place in the document, even if keep returns false for them. The root step is
+// always kept. Section numbers, anchors, and the table of contents are computed from the steps that
+// remain, so the numbering has no gaps. A branch to a step that isn't kept is left out.
+func (pcd *Procedure) RenderFiltered(f io.Writer, keep func(*Step) bool) error {
+	tplData, err := pcd.filteredRenderData(pcd.rootStep.AbsoluteName(), keep, true)
 	if err != nil {
 		return err
 	}
+	return pcd.renderMarkdown(f, tplData)
+}
 
-	step, err := pcd.GetStepByName(stepName)
+// renderMarkdown prints tplData as Markdown to f.
+func (pcd *Procedure) renderMarkdown(f io.Writer, tplData StepTemplateData) error {
+	tpl, err := DocTemplate()
 	if err != nil {
 		return err
 	}
-	tplData := NewStepTemplateData(step, nil, true)
 
 	var b strings.Builder
 	err = tpl.Execute(&b, tplData)
@@ -181,133 +794,1446 @@ func (pcd *Procedure) RenderStep(f io.Writer, stepName string) error {
 		return err
 	}
 
-	fmt.Fprintf(f, "%s", strings.Replace(b.String(), "@@", "`", -1))
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(b.String()))
 	return nil
 }
 
-// Execute runs through the procedure step by step.
-//
-// The user will be prompted as necessary.
-func (pcd *Procedure) Execute() error {
-	return pcd.ExecuteStep("root")
-}
-
-// ExecuteStep runs through the given step.
+// RenderAsciiDoc prints the procedure's AsciiDoc representation to f.
 //
-// The user will be prompted as necessary.
-func (pcd *Procedure) ExecuteStep(stepName string) error {
-	if _, err := pcd.Check(); err != nil {
-		return err
-	}
-
-	step, err := pcd.GetStepByName(stepName)
+// The document has the same structure as the Markdown produced by Render, with AsciiDoc section
+// headers, labeled blocks for inputs and outputs, and an anchor for each section. Pairs of backtick
+// standins ("@@") are replaced with backticks. See SetStrictStandins.
+func (pcd *Procedure) RenderAsciiDoc(f io.Writer) error {
+	tplData, err := pcd.markupRenderData(pcd.rootStep.AbsoluteName())
 	if err != nil {
 		return err
 	}
 
-	tpl, err := ExecTemplate()
+	tpl, err := AsciiDocTemplate()
 	if err != nil {
 		return err
 	}
 
-	step, err = pcd.GetStepByName(stepName)
+	var b strings.Builder
+	err = tpl.Execute(&b, tplData)
 	if err != nil {
 		return err
 	}
 
-	var skipTo string
-	step.Walk(func(walkStep *Step) error {
-		if skipTo != "" && walkStep.AbsoluteName() != skipTo {
-			fmt.Fprintf(pcd.stdout, "Skipping step '%s' on the way to '%s'\n", walkStep.AbsoluteName(), skipTo)
-			return nil
-		}
-
-		tplData := NewStepTemplateData(walkStep, nil, false)
-
-		var b bytes.Buffer
-		err = tpl.Execute(&b, tplData)
-		if err != nil {
-			return err
-		}
-		fmt.Fprintf(pcd.stdout, "%s", strings.Replace(b.String(), "@@", "`", -1))
-
-		promptResult := pcd.prompt()
-		if promptResult.SkipOne {
-			fmt.Fprintf(pcd.stdout, "Skipping step '%s' and its descendants\n", walkStep.AbsoluteName())
-			return NoRecurse
-		}
-		skipTo = promptResult.SkipTo
-		return nil
-	})
-
-	fmt.Fprintln(pcd.stdout, "Done.")
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(b.String()))
 	return nil
 }
 
-// promptResult is the struct returned by Procedure.prompt.
+// renderData checks the procedure and returns the template data for rendering the given step and
+// its descendants.
 //
-// Procedure.Execute uses the contents of a promptResult to decide what to do next.
-type promptResult struct {
-	// Whether to skip this step and its descendants.
-	SkipOne bool
-	// The absolute name of the next step that should be executed.
-	//
-	// If empty, Execute should proceed normally in its walk.
-	SkipTo string
+// The returned data reflects the procedure's rendering settings, such as SetHeadingOffset and
+// SetCollapseSingleChildChains.
+func (pcd *Procedure) renderData(stepName string) (StepTemplateData, error) {
+	return pcd.filteredRenderData(stepName, nil, false)
 }
 
-// prompt prompts the user for the next action to take.
+// markupRenderData returns the template data for rendering the given step as Markdown or
+// AsciiDoc, like renderData, with the bodies set by Step.LongLiteral escaped for the markup.
+func (pcd *Procedure) markupRenderData(stepName string) (StepTemplateData, error) {
+	return pcd.filteredRenderData(stepName, nil, true)
+}
+
+// filteredRenderData returns the template data for rendering the given step, like renderData,
+// pruned to the steps for which keep returns true and their ancestors.
 //
-// If the user enters an invalid choice, prompt will inform them of this and re-prompt until a valid
-// choice is entered.
-func (pcd *Procedure) prompt() promptResult {
-	// promptOnce prompts the user for input. It returns their input, trimmed of leading and
-	// trailing whitespace.
-	promptOnce := func() (string, error) {
-		fmt.Fprintf(pcd.stdout, "\n\n[Enter] to proceed (or \"help\"): ")
-		entry, err := bufio.NewReader(pcd.stdin).ReadBytes('\n')
-		fmt.Fprintf(pcd.stdout, "\n")
-		return strings.TrimSpace(string(entry)), err
+// If keep is nil, no steps are pruned. markup has the same meaning as for markupRenderData.
+func (pcd *Procedure) filteredRenderData(stepName string, keep func(*Step) bool, markup bool) (StepTemplateData, error) {
+	if _, err := pcd.Check(); err != nil {
+		return StepTemplateData{}, err
 	}
 
-	for {
-		entry, err := promptOnce()
-		if err != nil {
-			fmt.Fprintf(pcd.stdout, "Error reading input: %s\n", err.Error())
-			continue
+	step, err := pcd.GetStepByName(stepName)
+	if err != nil {
+		return StepTemplateData{}, err
+	}
+	tplData := NewStepTemplateData(step, nil, true)
+	if keep != nil {
+		kept := make(map[string]bool)
+		step.Walk(func(s *Step) error {
+			if keep(s) {
+				for a := s; a != nil && !kept[a.AbsoluteName()]; a = a.parent {
+					kept[a.AbsoluteName()] = true
+				}
+			}
+			return nil
+		})
+		tplData = pruneSteps(tplData, kept)
+	}
+	if markup {
+		tplData.escapeLiteralBodies()
+	}
+	if err := pcd.expandTemplateData(&tplData); err != nil {
+		return StepTemplateData{}, err
+	}
+	if pcd.collapseChains {
+		tplData = collapseChains(tplData)
+	}
+	tplData.linkReferences()
+	tplData.setHeadingOffset(pcd.headingOffset)
+	tplData.setStepComment(pcd.stepComments)
+	if pcd.variablesTable {
+		tplData.setVariables()
+	}
+	var anchors map[string]string
+	if pcd.glossary {
+		anchors = tplData.setGlossary()
+	}
+	if pcd.inputStyle == InputStyleTable || pcd.glossary {
+		descriptions := make(map[string]string)
+		pcd.rootStep.Walk(func(step *Step) error {
+			for _, outputDef := range step.GetOutputDefs() {
+				descriptions[outputDef.Name] = outputDef.Short
+			}
+			return nil
+		})
+		tplData.setRows(descriptions, anchors, pcd.inputStyle == InputStyleTable)
+	}
+	return tplData, nil
+}
+
+// RenderSummary prints a compact summary of the procedure to f, with one line per step.
+//
+// Each line consists of the step's numeric path, its short description, and the first sentence of
+// its long description, like so:
+//
+//     3.1 Restart the server — Log in to the server and run the restart script.
+//
+// The first sentence is everything up to the first ". " or newline. Steps with no long description
+// are summarized by their numeric path and short description alone.
+func (pcd *Procedure) RenderSummary(f io.Writer) error {
+	if _, err := pcd.Check(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	err := pcd.rootStep.Walk(func(step *Step) error {
+		td := NewStepTemplateData(step, nil, false)
+		if err := pcd.expandTemplateData(&td); err != nil {
+			return err
+		}
+		parts := make([]string, 0)
+		if td.Depth > 0 {
+			parts = append(parts, td.numericPathToString())
+		}
+		parts = append(parts, td.Title)
+		if sentence := firstSentence(td.Body); sentence != "" {
+			parts = append(parts, "—", sentence)
+		}
+		fmt.Fprintln(&b, strings.Join(parts, " "))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(b.String()))
+	return nil
+}
+
+// String returns an indented outline of the procedure's steps, for debugging.
+//
+// Each line consists of a step's name and short description, indented two spaces per level of
+// depth, like so:
+//
+//     root: Restart the service
+//       drain: Drain traffic
+//       restart: Restart the server
+func (pcd *Procedure) String() string {
+	var b strings.Builder
+	pcd.rootStep.Walk(func(step *Step) error {
+		fmt.Fprintf(&b, "%s%s: %s\n", strings.Repeat("  ", step.Depth()), step.name, step.GetShort())
+		return nil
+	})
+	return b.String()
+}
+
+// legendEntry describes a marker that can appear in the rendered Markdown. See RenderLegend.
+type legendEntry struct {
+	// The marker as it appears in the Markdown.
+	Marker string
+	// What the marker means.
+	Meaning string
+	// Whether the marker appears in the section for the given step.
+	Used func(StepTemplateData) bool
+}
+
+// legendEntries lists every marker that RenderLegend can explain, in the order they're listed.
+var legendEntries = []legendEntry{
+	legendEntry{
+		Marker:  "**Expected**",
+		Meaning: "The outcome you should see after performing the step",
+		Used:    func(td StepTemplateData) bool { return td.ExpectedOutcome != "" },
+	},
+	legendEntry{
+		Marker:  "**Repeat**",
+		Meaning: "Perform the step, and its substeps, once for each item in the given list",
+		Used:    func(td StepTemplateData) bool { return td.RepeatFor != "" },
+	},
+	legendEntry{
+		Marker:  "**References**",
+		Meaning: "Outputs of other steps that are useful context for the step",
+		Used:    func(td StepTemplateData) bool { return len(td.References) > 0 },
+	},
+}
+
+// RenderLegend prints a legend explaining the markers used in the procedure's Markdown to f.
+//
+// Only markers that actually appear in the procedure are listed. If none do, nothing is printed.
+func (pcd *Procedure) RenderLegend(f io.Writer) error {
+	tplData, err := pcd.renderData(pcd.rootStep.AbsoluteName())
+	if err != nil {
+		return err
+	}
+
+	used := make([]bool, len(legendEntries))
+	var visit func(StepTemplateData)
+	visit = func(td StepTemplateData) {
+		for i, entry := range legendEntries {
+			if entry.Used(td) {
+				used[i] = true
+			}
+		}
+		for _, c := range td.Children {
+			visit(c)
+		}
+	}
+	visit(tplData)
+
+	lines := make([]string, 0)
+	for i, entry := range legendEntries {
+		if used[i] {
+			lines = append(lines, fmt.Sprintf("  - %s: %s", entry.Marker, entry.Meaning))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(f, "**Legend**:\n\n%s\n", strings.Join(lines, "\n"))
+	return nil
+}
+
+// firstSentence returns the first sentence of s.
+//
+// The first sentence is everything up to and including the first ". ", or up to the first newline,
+// whichever comes first.
+func firstSentence(s string) string {
+	if i := strings.Index(s, "\n"); i != -1 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, ". "); i != -1 {
+		s = s[:i+1]
+	}
+	return strings.TrimSpace(s)
+}
+
+// SetStatusWriter sets a writer to which a compact status line is written at each step
+// transition during execution.
+//
+// This allows an execution to be monitored from elsewhere, such as a log file or a status display,
+// without parsing the output meant for the user. Each line consists of the status, the absolute
+// name of the step it applies to, and the time elapsed since execution started, like so:
+//
+//     RUNNING root.restart.drain 00:01:23
+//
+// The status is RUNNING when a step starts and SKIPPED when a step is skipped. When execution
+// ends, a final line with no step name gives its outcome: DONE, STOPPED (see ExecuteUntil),
+// INTERRUPTED, ABORTED (see ErrAborted), or FAILED. If w is nil, which is the default, no status
+// is written.
+func (pcd *Procedure) SetStatusWriter(w io.Writer) {
+	pcd.statusWriter = w
+}
+
+// SetInterruptHandling sets whether ExecuteContext traps SIGINT.
+//
+// When enabled, hitting Ctrl-C during execution interrupts it gracefully, as if ExecuteContext's
+// context had been cancelled: progress is written to the state file, if one was set with
+// SetStateFile, and ExecuteContext returns an error wrapping ErrInterrupted. When disabled, which
+// is the default, SIGINT is left alone, so it terminates the program as usual.
+func (pcd *Procedure) SetInterruptHandling(enabled bool) {
+	pcd.handleInterrupt = enabled
+}
+
+// SetStateFile sets the path to which progress is written if execution is interrupted.
+//
+// The progress is the run report as of the interruption, in the JSON format described in
+// RunReport.MarshalJSON. If path is "", which is the default, no state file is written.
+func (pcd *Procedure) SetStateFile(path string) {
+	pcd.stateFile = path
+}
+
+// SetInput sets the reader from which the user's input is read during execution.
+//
+// By default, input is read from os.Stdin. Prompts, help, and error messages are written to
+// os.Stdout regardless, so r can be a pipe from a separate channel than the one on which the
+// output is displayed.
+func (pcd *Procedure) SetInput(r io.Reader) {
+	pcd.stdin = r
+}
+
+// SetAnswers sets a source of answers that is read before the user's input.
+//
+// During execution, each line of input is read from r until r is exhausted, and from then on from
+// the input set with SetInput. This allows some or all of the answers to the procedure's prompts
+// to be supplied in advance, with the user asked interactively for the rest. Each answer read
+// from r is echoed after its prompt, so that the output reads as if it had been typed, except that
+// RedactedValue is echoed in place of the value of a secret output. If r is nil, which is the
+// default, all input is read interactively.
+func (pcd *Procedure) SetAnswers(r io.Reader) {
+	pcd.answersIn = r
+}
+
+// Prerequisite adds a condition that must hold before the procedure is executed.
+//
+// At the start of execution, before any step is shown, the user is asked to confirm each
+// prerequisite individually, in the order they were added. If the user answers no to any of them,
+// execution is aborted with an error wrapping ErrAborted. Prerequisites are asked about whichever
+// step execution starts from.
+func (pcd *Procedure) Prerequisite(s string) {
+	pcd.prerequisites = append(pcd.prerequisites, s)
+}
+
+// GetPrerequisites returns the procedure's prerequisites, as added by Prerequisite().
+func (pcd *Procedure) GetPrerequisites() []string {
+	return pcd.prerequisites
+}
+
+// confirmPrerequisites asks the user to confirm each of the procedure's prerequisites.
+//
+// If the user answers no to any of them, confirmPrerequisites returns an error without asking about
+// the rest.
+func (pcd *Procedure) confirmPrerequisites() error {
+	if len(pcd.prerequisites) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(pcd.stdout, "Before starting, confirm each of the following prerequisites.\n\n")
+	for i, prereq := range pcd.prerequisites {
+		fmt.Fprintf(pcd.stdout, "Prerequisite %d of %d: %s\n", i+1, len(pcd.prerequisites), prereq)
+		met, err := pcd.promptYesNo("Is this prerequisite met?")
+		if err != nil {
+			return err
+		}
+		if !met {
+			return fmt.Errorf("Aborted because prerequisite was not met: %s: %w", prereq, ErrAborted)
+		}
+	}
+	fmt.Fprintf(pcd.stdout, "\n")
+	return nil
+}
+
+// CaptureEnv specifies that details of the environment should be recorded in the run report.
+//
+// At the start of execution, the hostname, the current user, and the values of the environment
+// variables named in vars are captured in the report's Hostname, User, and Env fields. An
+// environment variable is treated as secret, and its value redacted, if its name matches the name
+// of a secret output or contains any of the words in secretEnvWords (case-insensitively).
+//
+// CaptureEnv may be called more than once; the variables from all calls are captured.
+func (pcd *Procedure) CaptureEnv(vars ...string) {
+	pcd.captureEnv = true
+	pcd.envVars = append(pcd.envVars, vars...)
+}
+
+// secretEnvWords are the words that mark an environment variable as secret. See CaptureEnv.
+var secretEnvWords = []string{"PASSWORD", "SECRET", "TOKEN", "KEY"}
+
+// captureEnvironment records the environment in the run report, as described in CaptureEnv.
+func (pcd *Procedure) captureEnvironment() {
+	if !pcd.captureEnv {
+		return
+	}
+
+	pcd.report.Hostname, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		pcd.report.User = u.Username
+	}
+
+	secrets := pcd.secretNames()
+	pcd.report.Env = make(map[string]string)
+	for _, name := range pcd.envVars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if secrets[name] {
+			value = RedactedValue
+		}
+		for _, word := range secretEnvWords {
+			if strings.Contains(strings.ToUpper(name), word) {
+				value = RedactedValue
+			}
+		}
+		pcd.report.Env[name] = value
+	}
+}
+
+// Execute runs through the procedure step by step.
+//
+// The user will be prompted as necessary. Once the user proceeds past a manual step, they're
+// prompted for the value of each of the step's outputs, in the order the outputs were specified.
+// An optional output (see Step.OutputStringOptional) is prompted for like any other, but the user
+// may leave it empty.
+//
+// Input is read as the user types it, with no way to turn off the terminal's echo, so the value of
+// a secret output is visible while it's being entered. It's kept out of everything that Execute
+// prints or records afterward.
+func (pcd *Procedure) Execute() error {
+	return pcd.ExecuteStep(pcd.rootStep.AbsoluteName())
+}
+
+// ExecuteWithBaseline runs through the procedure step by step, like Execute, and compares the
+// user's responses to those in an approved baseline transcript.
+//
+// baseline holds the expected responses, one per line, in the order the user is prompted for
+// them: the same form as the input a scripted run would read from stdin. Once execution ends,
+// every line of input the user entered is compared with the corresponding baseline line, and a
+// description of each difference is returned, as are any responses missing from the run or the
+// baseline. Line endings are normalized as for user input. If there are no deviations, an empty
+// slice is returned.
+//
+// Secret values are compared like any others, but they're never shown in the deviations: both the
+// expected and the actual value are replaced with RedactedValue.
+//
+// If execution fails, the deviations up to that point are returned along with the error.
+func (pcd *Procedure) ExecuteWithBaseline(baseline io.Reader) ([]string, error) {
+	b, err := ioutil.ReadAll(baseline)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read baseline: %w", err)
+	}
+	expected := strings.Split(string(b), "\n")
+	if len(expected) > 0 && normalizeLine(expected[len(expected)-1]) == "" {
+		// The baseline's final newline doesn't start another response
+		expected = expected[:len(expected)-1]
+	}
+
+	responses := make([]response, 0)
+	pcd.responses = &responses
+	defer func() { pcd.responses = nil }()
+	err = pcd.Execute()
+
+	deviations := make([]string, 0)
+	for i := 0; i < len(expected) || i < len(responses); i++ {
+		switch {
+		case i >= len(responses):
+			if err != nil {
+				// The run was cut short, so the remaining responses were never prompted for.
+				break
+			}
+			deviations = append(deviations, fmt.Sprintf("Response %d: expected '%s', but there was no response", i+1, normalizeLine(expected[i])))
+		case i >= len(expected):
+			deviations = append(deviations, fmt.Sprintf("Response %d: got '%s', which isn't in the baseline", i+1, responses[i].shown()))
+		case normalizeLine(expected[i]) != responses[i].Entry:
+			want := normalizeLine(expected[i])
+			if responses[i].Secret {
+				want = RedactedValue
+			}
+			deviations = append(deviations, fmt.Sprintf("Response %d: expected '%s', got '%s'", i+1, want, responses[i].shown()))
+		}
+	}
+	return deviations, err
+}
+
+// shown returns the response as it may be shown to the user: its entry, or RedactedValue if it's
+// secret.
+func (r response) shown() string {
+	if r.Secret {
+		return RedactedValue
+	}
+	return r.Entry
+}
+
+// ExecuteContext runs through the procedure step by step until it finishes or ctx is done.
+//
+// If ctx is done before execution finishes, execution stops before the next step (or while
+// waiting for user input), the state file is written if one was set with SetStateFile, and an
+// error wrapping ErrInterrupted is returned. SIGINT is also treated as an interruption if
+// SetInterruptHandling is enabled.
+func (pcd *Procedure) ExecuteContext(ctx context.Context) error {
+	return pcd.executeStepContext(ctx, pcd.rootStep.AbsoluteName(), runState{})
+}
+
+// ExecuteStep runs through the given step.
+//
+// The user will be prompted as necessary.
+func (pcd *Procedure) ExecuteStep(stepName string) error {
+	return pcd.executeStepContext(context.Background(), stepName, runState{})
+}
+
+// ExecuteStepWithValues runs through the given step, as ExecuteStep does, with the given values
+// already collected.
+//
+// values maps output names to their values. Any input whose name is in values takes its value from
+// there instead of prompting the user, as if the output had been collected by an earlier step.
+// This allows a procedure to be executed programmatically, with some or all of its inputs supplied
+// in advance. Inputs that aren't in values are prompted for as usual.
+func (pcd *Procedure) ExecuteStepWithValues(stepName string, values map[string]string) error {
+	return pcd.executeStepContext(context.Background(), stepName, runState{Values: values})
+}
+
+// ExecuteStepOnly runs through the given step without its descendants.
+//
+// The step is shown to the user, and they're prompted for its outputs (or its automated
+// implementation is run), just as during ExecuteStep, but execution ends there. If the step repeats
+// for each item in a list, it's executed once for each item.
+func (pcd *Procedure) ExecuteStepOnly(stepName string) error {
+	return pcd.executeStepContext(context.Background(), stepName, runState{NoDescend: true})
+}
+
+// ExecuteUntil runs through the procedure from startStep, stopping once stopStep finishes.
+//
+// This is useful for procedures that are run in stages. stopStep is considered finished once it
+// and all of its descendants have been executed (or skipped). Execution then ends with "Stopped
+// after" in place of "Done.", and no further steps are executed. stopStep must be startStep or one
+// of its descendants.
+func (pcd *Procedure) ExecuteUntil(startStep, stopStep string) error {
+	start, err := pcd.GetStepByName(startStep)
+	if err != nil {
+		return err
+	}
+	stop, err := pcd.GetStepByName(stopStep)
+	if err != nil {
+		return err
+	}
+	if !isDescendant(stop, start) {
+		return fmt.Errorf("Stop step '%s' is not '%s' or one of its descendants", stopStep, startStep)
+	}
+	return pcd.executeStepContext(context.Background(), startStep, runState{StopAfter: stopStep})
+}
+
+// ExecuteSequence runs through the given steps, in the given order, without their descendants.
+//
+// This allows a custom sequence of steps to be executed, in an order other than the procedure's
+// own. Each step is executed as by ExecuteStepOnly, but values are carried from one step to the
+// next, so an output collected in one step is used for the inputs of later steps. Skipping to a
+// step skips the sequence's steps until that step is reached.
+//
+// Each name must be the absolute name of a step in the procedure, and at least one must be given.
+func (pcd *Procedure) ExecuteSequence(stepNames []string) error {
+	if len(stepNames) == 0 {
+		return errors.New("Sequence must contain at least one step")
+	}
+	return pcd.executeStepsContext(context.Background(), stepNames, runState{NoDescend: true})
+}
+
+// executeStepContext runs through the given step until it finishes or ctx is done.
+//
+// state is the initial state of the execution.
+func (pcd *Procedure) executeStepContext(ctx context.Context, stepName string, state runState) error {
+	return pcd.executeStepsContext(ctx, []string{stepName}, state)
+}
+
+// executeStepsContext runs through the given steps, one after another, until they finish or ctx
+// is done.
+//
+// state is the initial state of the execution, and it's shared by all the steps.
+func (pcd *Procedure) executeStepsContext(ctx context.Context, stepNames []string, state runState) error {
+	if _, err := pcd.Check(); err != nil {
+		return err
+	}
+
+	steps := make([]*Step, len(stepNames))
+	for i, stepName := range stepNames {
+		step, err := pcd.GetStepByName(stepName)
+		if err != nil {
+			return err
+		}
+		steps[i] = step
+	}
+
+	tpl, err := ExecTemplate()
+	if err != nil {
+		return err
+	}
+
+	if pcd.handleInterrupt {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+	}
+
+	pcd.startRun(ctx)
+	for name, value := range state.Values {
+		pcd.setValue(name, value)
+	}
+	err = pcd.confirmPrerequisites()
+	for _, step := range steps {
+		if err != nil || state.Stopped {
+			break
+		}
+		err = pcd.executeTree(ctx, step, tpl, &state)
+	}
+	if err == nil {
+		pcd.printSkipped(&state, ", which was never reached")
+	}
+	pcd.finishReport(err)
+	pcd.writeFinalStatus(err, state.Stopped)
+	if errors.Is(err, ErrInterrupted) {
+		fmt.Fprintf(pcd.stdout, "\n%s; to resume, execute the procedure from that step\n", err.Error())
+		if pcd.stateFile != "" {
+			if writeErr := pcd.writeStateFile(); writeErr != nil {
+				return fmt.Errorf("%w; also failed to write state file: %s", err, writeErr.Error())
+			}
+			fmt.Fprintf(pcd.stdout, "Progress written to %s\n", pcd.stateFile)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if state.Stopped {
+		fmt.Fprintf(pcd.stdout, "Stopped after %s\n", state.StopAfter)
+		return nil
+	}
+	fmt.Fprintln(pcd.stdout, "Done.")
+	return nil
+}
+
+// writeStateFile writes the run report to the state file.
+func (pcd *Procedure) writeStateFile() error {
+	j, err := json.MarshalIndent(pcd.report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pcd.stateFile, j, 0600)
+}
+
+// startRun prepares the procedure's run state at the start of execution.
+//
+// ctx is the context of the execution.
+func (pcd *Procedure) startRun(ctx context.Context) {
+	pcd.runCtx = ctx
+	if pcd.in == nil || pcd.in.src != pcd.stdin {
+		pcd.in = newLineReader(pcd.stdin)
+	}
+	if pcd.answersIn != nil && (pcd.answers == nil || pcd.answers.src != pcd.answersIn) {
+		pcd.answers = newLineReader(pcd.answersIn)
+	}
+	pcd.answersLeft = pcd.answersIn != nil
+	pcd.values = make(map[string]string)
+	pcd.report = &RunReport{Start: time.Now(), Steps: make([]StepReport, 0)}
+	pcd.captureEnvironment()
+}
+
+// getValue returns the value collected so far for the given name, and whether there is one.
+func (pcd *Procedure) getValue(name string) (string, bool) {
+	pcd.mu.Lock()
+	defer pcd.mu.Unlock()
+	value, ok := pcd.values[name]
+	return value, ok
+}
+
+// setValue records the value for the given name.
+func (pcd *Procedure) setValue(name string, value string) {
+	pcd.mu.Lock()
+	defer pcd.mu.Unlock()
+	pcd.values[name] = value
+}
+
+// writeStatus writes a status line for the given step to the status writer, if there is one.
+//
+// If stepName is "", the status applies to the execution as a whole. See SetStatusWriter.
+func (pcd *Procedure) writeStatus(status string, stepName string) {
+	if pcd.statusWriter == nil {
+		return
+	}
+
+	pcd.mu.Lock()
+	defer pcd.mu.Unlock()
+	elapsed := time.Since(pcd.report.Start) / time.Second
+	parts := []string{status}
+	if stepName != "" {
+		parts = append(parts, stepName)
+	}
+	parts = append(parts, fmt.Sprintf("%02d:%02d:%02d", elapsed/3600, elapsed/60%60, elapsed%60))
+	fmt.Fprintln(pcd.statusWriter, strings.Join(parts, " "))
+}
+
+// writeFinalStatus writes the status line giving the outcome of an execution, which ended with err
+// (nil if it succeeded). stopped says whether it ended early because of ExecuteUntil.
+func (pcd *Procedure) writeFinalStatus(err error, stopped bool) {
+	switch {
+	case errors.Is(err, ErrInterrupted):
+		pcd.writeStatus("INTERRUPTED", "")
+	case errors.Is(err, ErrAborted):
+		pcd.writeStatus("ABORTED", "")
+	case err != nil:
+		pcd.writeStatus("FAILED", "")
+	case stopped:
+		pcd.writeStatus("STOPPED", "")
+	default:
+		pcd.writeStatus("DONE", "")
+	}
+}
+
+// recordStep adds stepReport to the run report.
+func (pcd *Procedure) recordStep(stepReport StepReport) {
+	pcd.mu.Lock()
+	defer pcd.mu.Unlock()
+	pcd.report.Steps = append(pcd.report.Steps, stepReport)
+}
+
+// LastRunReport returns the report for the most recent execution of the procedure.
+//
+// If the procedure has not been executed, LastRunReport returns nil.
+func (pcd *Procedure) LastRunReport() *RunReport {
+	return pcd.report
+}
+
+// LastRunValues returns the values collected during the most recent execution of the procedure,
+// keyed by name.
+//
+// The values of secret outputs are left out. The returned map is a copy, so changes to it don't
+// affect the procedure. If the procedure has not been executed, LastRunValues returns nil.
+func (pcd *Procedure) LastRunValues() map[string]string {
+	pcd.mu.Lock()
+	defer pcd.mu.Unlock()
+	if pcd.values == nil {
+		return nil
+	}
+
+	secrets := pcd.secretNames()
+	values := make(map[string]string)
+	for name, value := range pcd.values {
+		if !secrets[name] {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// finishReport completes the run report at the end of execution.
+//
+// err is the error that ended execution, or nil if execution completed.
+func (pcd *Procedure) finishReport(err error) {
+	pcd.mu.Lock()
+	defer pcd.mu.Unlock()
+
+	pcd.report.End = time.Now()
+	if err != nil {
+		pcd.report.Error = err.Error()
+	}
+
+	secrets := pcd.secretNames()
+	pcd.report.Values = make(map[string]string)
+	for name, value := range pcd.values {
+		if secrets[name] {
+			value = RedactedValue
+		}
+		pcd.report.Values[name] = value
+	}
+}
+
+// secretNames returns the set of output names in the procedure that are secret.
+func (pcd *Procedure) secretNames() map[string]bool {
+	secrets := make(map[string]bool)
+	pcd.rootStep.Walk(func(step *Step) error {
+		for _, outputDef := range step.GetOutputDefs() {
+			if outputDef.Secret {
+				secrets[outputDef.Name] = true
+			}
+		}
+		return nil
+	})
+	return secrets
+}
+
+// repetition describes one iteration of a step that repeats for each item in a list.
+type repetition struct {
+	// The name of the list input over which the step repeats.
+	ListName string
+	// The index of the current item in the list, starting at 0.
+	Index int
+	// The number of items in the list.
+	Count int
+	// The current item.
+	Item string
+}
+
+// runState is the state of a single execution that's shared by all calls to executeTree.
+type runState struct {
+	// The absolute name of the step that the user has asked to skip to, or "" if there is no such
+	// step.
+	SkipTo string
+	// Whether to execute only the step that execution starts at, without its descendants.
+	NoDescend bool
+	// The number of steps skipped so far on the way to SkipTo.
+	Skipped int
+	// The absolute name of the step after which execution stops, or "" to execute to the end.
+	StopAfter string
+	// Whether the StopAfter step has finished, so that no more steps should be executed.
+	Stopped bool
+	// Values to record before execution starts, keyed by output name. See
+	// ExecuteStepWithValues.
+	Values map[string]string
+}
+
+// printSkipped prints the summary line for the steps skipped so far on the way to the skipto
+// target, if SetQuietSkips is enabled and any were skipped, and resets the count. suffix is
+// appended to the line.
+func (pcd *Procedure) printSkipped(state *runState, suffix string) {
+	if pcd.quietSkips && state.Skipped > 0 {
+		noun := "steps"
+		if state.Skipped == 1 {
+			noun = "step"
+		}
+		fmt.Fprintf(pcd.stdout, "Skipped %d %s on the way to '%s'%s\n", state.Skipped, noun, state.SkipTo, suffix)
+	}
+	state.Skipped = 0
+}
+
+// executeTree executes step and its descendants.
+//
+// Steps are executed in the same order as Step.Walk would visit them, except that a step defined
+// with RepeatFor is executed (along with its descendants) once for each item in its list input.
+//
+// state is shared by all calls to executeTree for a given execution.
+func (pcd *Procedure) executeTree(ctx context.Context, step *Step, tpl *template.Template, state *runState) error {
+	reps := []*repetition{nil}
+	if step.repeatFor != "" && (state.SkipTo == "" || state.SkipTo == step.AbsoluteName()) {
+		list, err := pcd.inputValue(step.repeatFor, "stringlist", true)
+		if err != nil {
+			return err
+		}
+		items := splitList(list)
+		reps = make([]*repetition, len(items))
+		for i, item := range items {
+			reps[i] = &repetition{ListName: step.repeatFor, Index: i, Count: len(items), Item: item}
+		}
+	}
+
+	for _, rep := range reps {
+		if ctx.Err() != nil {
+			return fmt.Errorf("Interrupted at step '%s': %w", step.AbsoluteName(), ErrInterrupted)
+		}
+
+		stepReport := StepReport{Name: step.AbsoluteName(), Start: time.Now()}
+		skipBranch := ""
+		if rep != nil {
+			stepReport.Item = rep.Item
+		}
+
+		if state.SkipTo != "" && step.AbsoluteName() != state.SkipTo {
+			if !pcd.quietSkips {
+				fmt.Fprintf(pcd.stdout, "Skipping step '%s' on the way to '%s'\n", step.AbsoluteName(), state.SkipTo)
+			}
+			state.Skipped++
+			stepReport.Skipped = true
+			pcd.writeStatus("SKIPPED", step.AbsoluteName())
+			pcd.recordStep(stepReport)
+		} else {
+			pcd.printSkipped(state, "")
+			pcd.writeStatus("RUNNING", step.AbsoluteName())
+			promptResult, err := pcd.executeOne(ctx, step, tpl, rep, &stepReport)
+			stepReport.Duration = time.Since(stepReport.Start)
+			if err != nil && ctx.Err() != nil {
+				err = fmt.Errorf("Interrupted at step '%s': %w", step.AbsoluteName(), ErrInterrupted)
+			}
+			if err != nil {
+				stepReport.Error = err.Error()
+				pcd.recordStep(stepReport)
+				return err
+			}
+			if promptResult.SkipOne {
+				fmt.Fprintf(pcd.stdout, "Skipping step '%s' and its descendants\n", step.AbsoluteName())
+				stepReport.Skipped = true
+				pcd.writeStatus("SKIPPED", step.AbsoluteName())
+				pcd.recordStep(stepReport)
+				continue
+			}
+			pcd.recordStep(stepReport)
+			state.SkipTo = promptResult.SkipTo
+			skipBranch = promptResult.SkipBranch
+		}
+
+		if state.NoDescend {
+			continue
+		}
+		for _, child := range step.children {
+			if child.AbsoluteName() == skipBranch {
+				fmt.Fprintf(pcd.stdout, "Skipping step '%s' and its descendants, since its branch wasn't taken\n", child.AbsoluteName())
+				pcd.writeStatus("SKIPPED", child.AbsoluteName())
+				pcd.recordStep(StepReport{Name: child.AbsoluteName(), Start: time.Now(), Skipped: true})
+				if state.StopAfter == child.AbsoluteName() || strings.HasPrefix(state.StopAfter, child.AbsoluteName()+".") {
+					// The stop step is in the branch not taken, so it's finished as far as it
+					// ever will be.
+					state.Stopped = true
+					return nil
+				}
+				continue
+			}
+			if err := pcd.executeTree(ctx, child, tpl, state); err != nil {
+				return err
+			}
+			if state.Stopped {
+				return nil
+			}
+		}
+	}
+	if step.AbsoluteName() == state.StopAfter {
+		state.Stopped = true
+	}
+	return nil
+}
+
+// executeOne executes a single step, without its descendants.
+//
+// The step is printed along with the values of its inputs, and then the user is prompted for the
+// next action. If the user proceeds normally, they're then prompted for the values of the step's
+// outputs.
+//
+// If the step has an expected outcome, the user is asked to confirm that the outcome matched before
+// being prompted for outputs.
+//
+// If the step is automated, its function is run instead of prompting the user, and the outputs it
+// sets are recorded.
+//
+// rep describes the current iteration if step repeats for each item in a list; otherwise it's nil.
+// What happens during execution is recorded in stepReport.
+func (pcd *Procedure) executeOne(ctx context.Context, step *Step, tpl *template.Template, rep *repetition, stepReport *StepReport) (promptResult, error) {
+	tplData := NewStepTemplateData(step, nil, false)
+	if err := pcd.expandTemplateData(&tplData); err != nil {
+		return promptResult{}, err
+	}
+	tplData.Body = wrapText(tplData.Body, pcd.wrapWidth)
+
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, tplData); err != nil {
+		return promptResult{}, err
+	}
+	fmt.Fprintf(pcd.stdout, "%s", pcd.replaceStandins(b.String()))
+
+	if err := pcd.printInputs(step, rep); err != nil {
+		return promptResult{}, err
+	}
+
+	pcd.runHook(ctx, step.beforePrompt, step, rep)
+	if step.IsAutomated() {
+		if err := pcd.runAutomated(ctx, step, rep); err != nil {
+			return promptResult{}, err
+		}
+		var result promptResult
+		skipBranch, err := pcd.promptBranch(step)
+		if err != nil {
+			return promptResult{}, err
+		}
+		result.SkipBranch = skipBranch
+		pcd.runHook(ctx, step.afterProceed, step, rep)
+		return result, nil
+	}
+
+	result, err := pcd.prompt()
+	if err != nil {
+		return promptResult{}, err
+	}
+	stepReport.Notes = result.Notes
+	if result.SkipOne || result.SkipTo != "" {
+		return result, nil
+	}
+
+	if step.GetExpectedOutcome() != "" {
+		matched, err := pcd.promptYesNo("Did the outcome match what was expected?")
+		if err != nil {
+			return promptResult{}, err
+		}
+		if !matched {
+			stepReport.OutcomeMismatch = true
+			fmt.Fprintf(pcd.stdout, "Discrepancy recorded\n")
+		}
+	}
+
+	for _, outputDef := range step.GetOutputDefs() {
+		if err := pcd.promptOutput(outputDef); err != nil {
+			return promptResult{}, err
+		}
+	}
+	result.SkipBranch, err = pcd.promptBranch(step)
+	if err != nil {
+		return promptResult{}, err
+	}
+	pcd.runHook(ctx, step.afterProceed, step, rep)
+	return result, nil
+}
+
+// promptBranch asks the user the question of step's branch, if it has one, and returns the
+// absolute name of the child whose branch wasn't taken.
+//
+// If step doesn't branch, promptBranch returns "".
+func (pcd *Procedure) promptBranch(step *Step) (string, error) {
+	branch := step.GetBranch()
+	if branch == nil {
+		return "", nil
+	}
+	yes, err := pcd.promptYesNo(branch.Question)
+	if err != nil {
+		return "", err
+	}
+	taken, notTaken := branch.YesStep, branch.NoStep
+	if !yes {
+		taken, notTaken = notTaken, taken
+	}
+	fmt.Fprintf(pcd.stdout, "Taking branch '%s'\n", childNamed(step, taken).AbsoluteName())
+	return childNamed(step, notTaken).AbsoluteName(), nil
+}
+
+// printInputs prints the values of step's inputs.
+//
+// If an input has no value yet (which happens when execution starts partway through the
+// procedure), the user is prompted for it. Inputs are handled in the order set by
+// Step.PromptOrder.
+func (pcd *Procedure) printInputs(step *Step, rep *repetition) error {
+	inputDefs := step.orderedInputDefs()
+	if len(inputDefs) == 0 {
+		return nil
+	}
+
+	secrets := pcd.secretNames()
+	fmt.Fprintf(pcd.stdout, "\n\nInputs:\n")
+	for _, inputDef := range inputDefs {
+		if rep != nil && inputDef.Name == rep.ListName {
+			fmt.Fprintf(pcd.stdout, "  - %s: %s (item %d of %d)\n", inputDef.Name, rep.Item, rep.Index+1, rep.Count)
+			continue
+		}
+
+		value, err := pcd.inputValue(inputDef.Name, inputDef.ValueType, inputDef.Required)
+		if err != nil {
+			return err
+		}
+		if secrets[inputDef.Name] {
+			fmt.Fprintf(pcd.stdout, "  - %s: %s\n", inputDef.Name, RedactedValue)
+			continue
+		}
+		if inputDef.ValueType == "stringlist" {
+			fmt.Fprintf(pcd.stdout, "  - %s:\n", inputDef.Name)
+			for _, item := range splitList(value) {
+				fmt.Fprintf(pcd.stdout, "      - %s\n", item)
+			}
+			continue
+		}
+		fmt.Fprintf(pcd.stdout, "  - %s: %s\n", inputDef.Name, value)
+	}
+	return nil
+}
+
+// inputValue returns the value of the named input.
+//
+// If no value has been collected for the input yet, the user is prompted for one. If some step in
+// the procedure produces an output with the same name, the prompt says so, since the user is
+// probably executing the procedure from partway through.
+func (pcd *Procedure) inputValue(name string, valueType string, required bool) (string, error) {
+	if value, ok := pcd.getValue(name); ok {
+		return value, nil
+	}
+
+	desc := fmt.Sprintf("Value for input '%s'", name)
+	if producer := pcd.producerOf(name); producer != nil {
+		desc = fmt.Sprintf("%s (normally produced by step '%s')", desc, producer.AbsoluteName())
+	}
+	value, err := pcd.promptValue(desc, valueType, required, pcd.secretNames()[name])
+	if err != nil {
+		return "", err
+	}
+	pcd.setValue(name, value)
+	return value, nil
+}
+
+// producerOf returns the first step in the procedure that produces the named output, or nil if
+// there is none.
+func (pcd *Procedure) producerOf(name string) *Step {
+	var producer *Step
+	pcd.rootStep.Walk(func(step *Step) error {
+		for _, outputDef := range step.GetOutputDefs() {
+			if producer == nil && outputDef.Name == name {
+				producer = step
+			}
+		}
+		return nil
+	})
+	return producer
+}
+
+// promptOutput prompts the user for the value of the given output and records it.
+func (pcd *Procedure) promptOutput(outputDef OutputDef) error {
+	desc := fmt.Sprintf("%s (%s)", outputDef.Short, outputDef.Name)
+	if outputDef.Example != "" {
+		desc = fmt.Sprintf("%s (%s, e.g. %s)", outputDef.Short, outputDef.Name, outputDef.Example)
+	}
+	value, err := pcd.promptValue(desc, outputDef.ValueType, !outputDef.Optional, outputDef.Secret)
+	if err != nil {
+		return err
+	}
+	pcd.setValue(outputDef.Name, value)
+	return nil
+}
+
+// promptValue prompts the user for a value of the given type, described to them by desc.
+//
+// The value is read as described in readValue. If SetConfirmInputs is enabled, the user is then
+// asked to confirm the value, and prompted for it again until they do. If secret is true, the value
+// isn't shown to them in the confirmation.
+func (pcd *Procedure) promptValue(desc string, valueType string, required bool, secret bool) (string, error) {
+	for {
+		pcd.secretPrompt = secret
+		value, err := pcd.readValue(desc, valueType, required)
+		pcd.secretPrompt = false
+		if err != nil || !pcd.confirmInputs {
+			return value, err
+		}
+
+		entered := fmt.Sprintf("'%s'", strings.Replace(value, "\n", ", ", -1))
+		if secret {
+			entered = "a secret value"
+		}
+		correct, err := pcd.promptYesNo(fmt.Sprintf("You entered %s. Correct?", entered))
+		if err != nil {
+			return "", err
+		}
+		if correct {
+			return value, nil
+		}
+	}
+}
+
+// readValue reads a value of the given type from the user, described to them by desc.
+//
+// A "stringlist" value is read one item per line until the user enters an empty line, and the items
+// are returned joined by newlines. Any other value is read from a single line. If required is true,
+// the user is re-prompted until they enter a non-empty single-line value. An "int" value must be an
+// integer.
+func (pcd *Procedure) readValue(desc string, valueType string, required bool) (string, error) {
+	if valueType == "stringlist" {
+		fmt.Fprintf(pcd.stdout, "%s, one per line (empty line to finish):\n", desc)
+		items := make([]string, 0)
+		for {
+			item, err := pcd.readLine()
+			if err != nil {
+				return "", err
+			}
+			if item == "" {
+				return strings.Join(items, "\n"), nil
+			}
+			items = append(items, item)
+		}
+	}
+
+	for {
+		fmt.Fprintf(pcd.stdout, "%s: ", desc)
+		value, err := pcd.readLine()
+		if err != nil {
+			return "", err
+		}
+		if value == "" {
+			if !required {
+				return value, nil
+			}
+			fmt.Fprintf(pcd.stdout, "A value is required\n")
+			continue
+		}
+		if valueType == "int" {
+			if _, err := strconv.Atoi(value); err != nil {
+				fmt.Fprintf(pcd.stdout, "Value must be an integer\n")
+				continue
+			}
+		}
+		return value, nil
+	}
+}
+
+// promptYesNo asks the user the given yes-or-no question and returns their answer.
+//
+// If the user enters anything other than "y", "yes", "n", or "no" (case-insensitively), they're
+// re-prompted.
+func (pcd *Procedure) promptYesNo(question string) (bool, error) {
+	for {
+		fmt.Fprintf(pcd.stdout, "%s [y/n]: ", question)
+		entry, err := pcd.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(entry) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintf(pcd.stdout, "Please enter 'y' or 'n'\n")
+	}
+}
+
+// readLine reads a line of input from the user, trimmed of leading and trailing whitespace.
+//
+// All reading of user input goes through readLine, so this is where line endings are normalized:
+// a CRLF line ending, as sent by terminals on Windows, is treated the same as LF, and any other
+// carriage returns are removed. That way, commands like "skipto STEP" parse the same on every
+// platform.
+//
+// If the execution's context is done before a line is read, readLine returns the context's error.
+//
+// During ExecuteWithBaseline, every line read is also recorded, marked as secret if it's the value
+// of a secret output.
+func (pcd *Procedure) readLine() (string, error) {
+	entry, err := pcd.readEntry()
+	if err == nil && pcd.responses != nil {
+		*pcd.responses = append(*pcd.responses, response{Entry: entry, Secret: pcd.secretPrompt})
+	}
+	return entry, err
+}
+
+// readEntry reads a line of input for readLine, without recording it.
+//
+// The line is read from the answers set with SetAnswers, if any remain, and otherwise from the
+// input.
+func (pcd *Procedure) readEntry() (string, error) {
+	if pcd.answersLeft {
+		entry, err := pcd.readFrom(pcd.answers)
+		if err == io.EOF {
+			pcd.answersLeft = false
+			if entry != "" {
+				err = nil
+			}
+		}
+		if err == nil {
+			echo := normalizeLine(entry)
+			if pcd.secretPrompt {
+				echo = RedactedValue
+			}
+			fmt.Fprintf(pcd.stdout, "%s\n", echo)
+			return normalizeLine(entry), nil
+		}
+		if pcd.answersLeft {
+			return "", err
+		}
+	}
+
+	entry, err := pcd.readFrom(pcd.in)
+	return normalizeLine(entry), err
+}
+
+// readFrom reads a line from r, including its line ending.
+//
+// If the execution's context is done before a line is read, readFrom returns the context's error.
+// The read carries on in the background, and its line is returned by the next call to readFrom on
+// r, so no input is lost.
+func (pcd *Procedure) readFrom(r *lineReader) (string, error) {
+	return r.readLine(pcd.runCtx)
+}
+
+// A lineReader reads lines from an io.Reader, allowing a wait for a line to be cut short without
+// losing it.
+type lineReader struct {
+	// The reader from which lines are read.
+	src io.Reader
+	in  *bufio.Reader
+	// The channel on which the line being read in the background is delivered, or nil if no read is
+	// in progress.
+	pending chan lineRead
+}
+
+// A lineRead is the result of reading a line from a lineReader.
+type lineRead struct {
+	entry string
+	err   error
+}
+
+// newLineReader returns a lineReader that reads lines from src.
+func newLineReader(src io.Reader) *lineReader {
+	return &lineReader{src: src, in: bufio.NewReader(src)}
+}
+
+// readLine reads a line from lr, including its line ending.
+//
+// If ctx is done before the line is read, readLine returns the context's error and leaves the read
+// in progress, so that the next call to readLine returns its line. ctx may be nil, in which case
+// readLine waits for the line.
+func (lr *lineReader) readLine(ctx context.Context) (string, error) {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	if lr.pending == nil {
+		lr.pending = make(chan lineRead, 1)
+		go func(ch chan<- lineRead) {
+			entry, err := lr.in.ReadString('\n')
+			ch <- lineRead{entry: entry, err: err}
+		}(lr.pending)
+	}
+	select {
+	case l := <-lr.pending:
+		lr.pending = nil
+		return l.entry, l.err
+	case <-done:
+		return "", ctx.Err()
+	}
+}
+
+// normalizeLine removes carriage returns from a line of user input and trims it of leading and
+// trailing whitespace.
+func normalizeLine(entry string) string {
+	return strings.TrimSpace(strings.Replace(entry, "\r", "", -1))
+}
+
+// splitList splits a "stringlist" value into its items.
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, "\n")
+}
+
+// promptResult is the struct returned by Procedure.prompt.
+//
+// Procedure.Execute uses the contents of a promptResult to decide what to do next.
+type promptResult struct {
+	// Whether to skip this step and its descendants.
+	SkipOne bool
+	// The absolute name of the next step that should be executed.
+	//
+	// If empty, Execute should proceed normally in its walk.
+	SkipTo string
+	// Any notes the user entered before choosing what to do next.
+	Notes []string
+	// The absolute name of the child to skip because its branch wasn't taken, or "" for none.
+	SkipBranch string
+}
+
+// prompt prompts the user for the next action to take.
+//
+// If the user enters an invalid choice, prompt will inform them of this and re-prompt until a valid
+// choice is entered. An error is returned only if execution is interrupted.
+func (pcd *Procedure) prompt() (promptResult, error) {
+	if pcd.menuPrompt {
+		return pcd.promptMenu()
+	}
+
+	// promptOnce prompts the user for input. It returns their input, trimmed of leading and
+	// trailing whitespace.
+	promptOnce := func() (string, error) {
+		fmt.Fprintf(pcd.stdout, "\n\n[Enter] to proceed (or \"help\"): ")
+		entry, err := pcd.readLine()
+		fmt.Fprintf(pcd.stdout, "\n")
+		return entry, err
+	}
+
+	var notes []string
+	for {
+		entry, err := promptOnce()
+		if err != nil && pcd.runCtx != nil && pcd.runCtx.Err() != nil {
+			return promptResult{}, err
+		}
+		if err != nil {
+			fmt.Fprintf(pcd.stdout, "Error reading input: %s\n", err.Error())
+			continue
 		}
 
 		if entry == "" {
 			// Proceed to the next step as normal
-			return promptResult{}
+			return promptResult{Notes: notes}, nil
 		}
 		if entry == "help" {
 			// Print the help message and prompt again
 			pcd.printPromptHelp()
 		}
 		if entry == "skip" {
-			return promptResult{SkipOne: true}
+			return promptResult{SkipOne: true, Notes: notes}, nil
 		}
 		if strings.HasPrefix(entry, "skipto ") {
 			parts := strings.Split(entry, " ")
 			if len(parts) != 2 || len(parts[1]) == 0 {
 				fmt.Fprintf(pcd.stdout, "Invalid 'skipto' syntax; enter \"help\" for help\n")
 			}
-			return promptResult{SkipTo: parts[1]}
+			return promptResult{SkipTo: parts[1], Notes: notes}, nil
+		}
+		if strings.HasPrefix(entry, "note ") {
+			// Record the note and prompt again
+			notes = append(notes, strings.TrimSpace(strings.TrimPrefix(entry, "note ")))
+			fmt.Fprintf(pcd.stdout, "Note recorded\n")
+			continue
 		}
 
 		fmt.Fprintf(pcd.stdout, "Invalid choice; enter \"help\" for help\n")
 	}
 }
 
+// promptMenu prompts the user for the next action to take with a numbered menu.
+//
+// It's the counterpart of prompt for when SetMenuPrompt is enabled. If the user enters an invalid
+// choice, promptMenu will inform them of this and re-prompt until a valid choice is entered. An
+// error is returned if the user chooses to quit or execution is interrupted.
+func (pcd *Procedure) promptMenu() (promptResult, error) {
+	for {
+		fmt.Fprintf(pcd.stdout, `
+
+  1) Proceed to the next step
+  2) Skip this step and its descendants
+  3) Skip to another step
+  4) Quit
+Choice [1]: `)
+		entry, err := pcd.readLine()
+		fmt.Fprintf(pcd.stdout, "\n")
+		if err != nil {
+			return promptResult{}, err
+		}
+
+		switch entry {
+		case "", "1":
+			return promptResult{}, nil
+		case "2":
+			return promptResult{SkipOne: true}, nil
+		case "3":
+			fmt.Fprintf(pcd.stdout, "Absolute name of the step to skip to: ")
+			stepName, err := pcd.readLine()
+			if err != nil {
+				return promptResult{}, err
+			}
+			if stepName == "" {
+				continue
+			}
+			return promptResult{SkipTo: stepName}, nil
+		case "4":
+			return promptResult{}, fmt.Errorf("Execution quit at the user's request: %w", ErrAborted)
+		}
+		fmt.Fprintf(pcd.stdout, "Invalid choice; enter a number from 1 to 4\n")
+	}
+}
+
+// A PromptCommand is a command that the user can enter at the Execute prompt.
+type PromptCommand struct {
+	// The command's name, such as "skipto".
+	Name string
+	// How the command is entered, such as "skipto STEP".
+	Usage string
+	// A short description of what the command does.
+	Description string
+}
+
+// PromptCommands returns the commands that the user can enter at the Execute prompt, in the order
+// they're listed by the prompt's "help" command.
+//
+// This lets other interfaces that embed donothing present the commands themselves. When
+// SetMenuPrompt is enabled, the prompt offers numbered equivalents of these commands instead.
+func (pcd *Procedure) PromptCommands() []PromptCommand {
+	return []PromptCommand{
+		PromptCommand{Name: "proceed", Usage: "[Enter]", Description: "Proceed to the next step"},
+		PromptCommand{Name: "skip", Usage: "skip", Description: "Skip this step and its descendants"},
+		PromptCommand{Name: "skipto", Usage: "skipto STEP", Description: "Skip to the given step by absolute name"},
+		PromptCommand{Name: "note", Usage: "note TEXT", Description: "Record a note about this step in the run report"},
+		PromptCommand{Name: "help", Usage: "help", Description: "Print this help message"},
+	}
+}
+
 // printPromptHelp prints the help message for the Execute prompt.
 func (pcd *Procedure) printPromptHelp() {
-	fmt.Fprintf(pcd.stdout, `Options:
-
-[Enter]			Proceed to the next step
-skip			Skip this step and its descendants
-skipto STEP 	Skip to the given step by absolute name
-help			Print this help message`)
+	lines := []string{"Options:", ""}
+	for _, cmd := range pcd.PromptCommands() {
+		lines = append(lines, fmt.Sprintf("%-16s%s", cmd.Usage, cmd.Description))
+	}
+	fmt.Fprintf(pcd.stdout, "%s", strings.Join(lines, "\n"))
 }
 
 // NewProcedure returns a new procedure, ready to be given steps.
@@ -3,9 +3,15 @@ package donothing
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -178,3 +184,2866 @@ func TestProcedure_ExecuteStep_Nested(t *testing.T) {
 	_, err = readThrough(stdoutBufReader, []byte("Done.\n"), 5*time.Second)
 	assert.Nil(err)
 }
+
+// ExecuteStep should wrap step bodies at the width given to SetWrapWidth, while Render should not.
+// ExecuteStepOnly should execute the given step and collect its outputs, but not execute its
+// children.
+func TestProcedure_ExecuteStepOnly(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.OutputString("DrainedHost", "The drained host")
+		step.AddStep(func(step *Step) {
+			step.Name("checkDrained")
+			step.Short("Check that traffic is drained")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root.drain
+		"",
+		"web-1",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteStepOnly("root.drain"))
+	assert.Contains(stdout.String(), "Drain traffic")
+	assert.NotContains(stdout.String(), "Restart the service")
+	assert.NotContains(stdout.String(), "Check that traffic is drained")
+	assert.NotContains(stdout.String(), "Restart the server")
+	assert.Equal(map[string]string{"DrainedHost": "web-1"}, pcd.LastRunValues())
+	assert.Equal(1, len(pcd.LastRunReport().Steps))
+}
+
+// ExecuteUntil should stop right after the stop step and its descendants finish.
+func TestProcedure_ExecuteUntil(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Migrate the database")
+	pcd.AddStep(func(step *Step) {
+		step.Name("prepare")
+		step.Short("Prepare the migration")
+		step.AddStep(func(step *Step) {
+			step.Name("snapshot")
+			step.Short("Take a snapshot")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("migrate")
+		step.Short("Run the migration")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.prepare
+		"",
+		// root.prepare.snapshot
+		"",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteUntil("root", "root.prepare"))
+	assert.Contains(stdout.String(), "Take a snapshot")
+	assert.True(strings.HasSuffix(stdout.String(), "Stopped after root.prepare\n"))
+	assert.NotContains(stdout.String(), "Run the migration")
+	assert.NotContains(stdout.String(), "Done.")
+	assert.Equal(3, len(pcd.LastRunReport().Steps))
+
+	assert.NotNil(pcd.ExecuteUntil("root", "root.nonexistent"))
+	assert.NotNil(pcd.ExecuteUntil("root.nonexistent", "root.prepare"))
+	assert.NotNil(pcd.ExecuteUntil("root.migrate", "root.prepare"))
+}
+
+// SetWrapWidth should wrap step bodies during Execute, but not in rendered Markdown.
+func TestProcedure_SetWrapWidth(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("root step")
+	pcd.Long(`
+		one two three four five six
+
+		    seven eight nine ten eleven
+	`)
+	pcd.SetWrapWidth(10)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "one two three four five six")
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	stdoutBufReader := bufio.NewReader(stdoutReader)
+	pcd.stdin = stdinReader
+	pcd.stdout = stdoutWriter
+
+	go pcd.ExecuteStep("root")
+
+	output, err := readThrough(stdoutBufReader, []byte(": "), 5*time.Second)
+	assert.Nil(err)
+	assert.Contains(string(output), "one two\nthree four\nfive six")
+	assert.Contains(string(output), "    seven eight nine ten eleven")
+
+	stdinWriter.Write([]byte("\n"))
+	_, err = readThrough(stdoutBufReader, []byte("Done.\n"), 5*time.Second)
+	assert.Nil(err)
+}
+
+// RenderStepText should wrap step bodies at the width given to SetWrapWidth.
+func TestProcedure_SetWrapWidth_RenderStepText(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("root step")
+	pcd.Long(`
+		one two three four five six
+
+		    seven eight nine ten eleven
+	`)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderStepText(&b, "root"))
+	assert.Contains(b.String(), "one two three four five six\n")
+
+	pcd.SetWrapWidth(10)
+	b.Reset()
+	assert.Nil(pcd.RenderStepText(&b, "root"))
+	assert.Contains(b.String(), "one two\nthree four\nfive six\n\n    seven eight nine ten eleven")
+}
+
+// Check should report steps nested deeper than the maximum set with SetMaxDepth.
+func TestProcedure_SetMaxDepth(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Go deep")
+	pcd.AddStep(func(a *Step) {
+		a.Name("a")
+		a.Short("Step a")
+		a.AddStep(func(b *Step) {
+			b.Name("b")
+			b.Short("Step b")
+			b.AddStep(func(c *Step) {
+				c.Name("c")
+				c.Short("Step c")
+			})
+		})
+	})
+
+	problems, err := pcd.Check()
+	assert.Nil(err)
+	assert.Equal([]string{}, problems)
+
+	pcd.SetMaxDepth(2)
+	problems, err = pcd.Check()
+	assert.True(errors.Is(err, ErrCheckFailed))
+	assert.Equal([]string{
+		"Step 'root.a.b.c' is nested 3 steps deep, deeper than the maximum of 2",
+	}, problems)
+	assert.True(errors.Is(pcd.Render(&bytes.Buffer{}), ErrCheckFailed))
+
+	pcd.SetMaxDepth(3)
+	_, err = pcd.Check()
+	assert.Nil(err)
+}
+
+// ExecuteStep should collect a string list output and repeat a RepeatFor step once per item.
+func TestProcedure_ExecuteStep_RepeatFor(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart pods")
+	pcd.AddStep(func(step *Step) {
+		step.Name("listPods")
+		step.Short("List affected pods")
+		step.OutputStringList("PodNames", "Affected pod names")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restartPod")
+		step.Short("Restart a pod")
+		step.RepeatFor("PodNames")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("finish")
+		step.Short("Finish up")
+	})
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	stdoutBufReader := bufio.NewReader(stdoutReader)
+	pcd.stdin = stdinReader
+	pcd.stdout = stdoutWriter
+
+	go pcd.ExecuteStep("root")
+
+	prompt := []byte("(or \"help\"): ")
+
+	// root
+	_, err := readThrough(stdoutBufReader, prompt, 5*time.Second)
+	assert.Nil(err)
+	stdinWriter.Write([]byte("\n"))
+
+	// root.listPods
+	output, err := readThrough(stdoutBufReader, prompt, 5*time.Second)
+	assert.Nil(err)
+	assert.Contains(string(output), "List affected pods")
+	stdinWriter.Write([]byte("\n"))
+	_, err = readThrough(stdoutBufReader, []byte("(empty line to finish):\n"), 5*time.Second)
+	assert.Nil(err)
+	stdinWriter.Write([]byte("pod-a\npod-b\n\n"))
+
+	// root.restartPod, once per pod
+	output, err = readThrough(stdoutBufReader, prompt, 5*time.Second)
+	assert.Nil(err)
+	assert.Contains(string(output), "Restart a pod")
+	assert.Contains(string(output), "PodNames: pod-a (item 1 of 2)")
+	stdinWriter.Write([]byte("\n"))
+
+	output, err = readThrough(stdoutBufReader, prompt, 5*time.Second)
+	assert.Nil(err)
+	assert.Contains(string(output), "Restart a pod")
+	assert.Contains(string(output), "PodNames: pod-b (item 2 of 2)")
+	stdinWriter.Write([]byte("\n"))
+
+	// root.finish
+	output, err = readThrough(stdoutBufReader, prompt, 5*time.Second)
+	assert.Nil(err)
+	assert.Contains(string(output), "Finish up")
+	assert.NotContains(string(output), "Restart a pod")
+	stdinWriter.Write([]byte("\n"))
+
+	_, err = readThrough(stdoutBufReader, []byte("Done.\n"), 5*time.Second)
+	assert.Nil(err)
+}
+
+// Render should document string list outputs and the steps that repeat over them.
+func TestProcedure_Render_RepeatFor(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart pods")
+	pcd.AddStep(func(step *Step) {
+		step.Name("listPods")
+		step.Short("List affected pods")
+		step.OutputStringList("PodNames", "Affected pod names")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restartPod")
+		step.Short("Restart a pod")
+		step.RepeatFor("PodNames")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "`PodNames` (stringlist): Affected pod names")
+	assert.Contains(b.String(), "**Repeat** this step for each item in `PodNames`.")
+}
+
+// A LongLiteral body should have its markdown metacharacters escaped.
+func TestProcedure_Render_LongLiteral(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("report")
+		step.Short("Report the error")
+		step.LongLiteral("# Error: *bad* value in `config` @@here@@")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "\\# Error: \\*bad\\* value in \\`config\\` \\@\\@here\\@\\@")
+}
+
+// A LongLiteral body should be shown as written everywhere other than Markdown.
+func TestProcedure_LongLiteral_Unescaped(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	raw := "Run kubectl get pods -n prod. Check *all* of them @@here@@ {{.Var \"x\"}}"
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.SetVars(map[string]string{})
+	pcd.AddStep(func(step *Step) {
+		step.Name("check")
+		step.Short("Check the pods")
+		step.LongLiteral(raw)
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderStepText(&b, "root.check"))
+	assert.Contains(b.String(), raw)
+
+	b.Reset()
+	assert.Nil(pcd.RenderStepJSON(&b, "root.check"))
+	var js jsonStep
+	assert.Nil(json.Unmarshal(b.Bytes(), &js))
+	assert.Equal(raw, js.Long)
+
+	b.Reset()
+	assert.Nil(pcd.RenderStepHTML(&b, "root.check"))
+	assert.Contains(b.String(), "Check *all* of them @@here@@")
+
+	b.Reset()
+	assert.Nil(pcd.RenderAsciiDoc(&b))
+	assert.Contains(b.String(), "Check \\*all\\* of them")
+
+	pcd.stdin = strings.NewReader("\n\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), raw)
+}
+
+// Render should shift all headers down by the offset given to SetHeadingOffset.
+func TestProcedure_SetHeadingOffset(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("child")
+		step.Short("Child step")
+		step.AddStep(func(step *Step) {
+			step.Name("grandchild")
+			step.Short("Grandchild step")
+		})
+	})
+	pcd.SetHeadingOffset(1)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "## Root step\n")
+	assert.Contains(b.String(), "\n### (0) Child step\n")
+	assert.Contains(b.String(), "\n#### (0.0) Grandchild step")
+	assert.NotContains(b.String(), "\n# ")
+	// Anchors are unaffected by the offset
+	assert.Contains(b.String(), "[Up](#0-child-step)")
+
+	// Negative offsets never shift headers above "#"
+	pcd.SetHeadingOffset(-1)
+	b.Reset()
+	assert.Nil(pcd.Render(&b))
+	assert.True(strings.HasPrefix(b.String(), "# Root step\n"))
+	assert.Contains(b.String(), "\n# (0) Child step\n")
+	assert.Contains(b.String(), "\n## (0.0) Grandchild step")
+}
+
+// RenderSummary should print one line per step with the first sentence of its body.
+func TestProcedure_RenderSummary(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		// The long description of the procedure's only child step
+		Long string
+		// The expected summary line for that step
+		Exp string
+	}
+
+	testCases := []testCase{
+		testCase{
+			Long: "",
+			Exp:  "0 Drain traffic\n",
+		},
+		testCase{
+			Long: "Remove the host from the pool. Then wait.",
+			Exp:  "0 Drain traffic — Remove the host from the pool.\n",
+		},
+		testCase{
+			Long: `
+				Remove the host from the @@lb@@ pool
+				and wait. Really, wait.
+			`,
+			Exp: "0 Drain traffic — Remove the host from the `lb` pool\n",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Restart the service")
+		pcd.Long("Restarts the service. Takes a while.")
+		pcd.AddStep(func(step *Step) {
+			step.Name("drain")
+			step.Short("Drain traffic")
+			step.Long(tc.Long)
+			step.AddStep(func(step *Step) {
+				step.Name("verify")
+				step.Short("Verify drained")
+			})
+		})
+
+		var b bytes.Buffer
+		assert.Nil(pcd.RenderSummary(&b))
+		assert.Equal("Restart the service — Restarts the service.\n"+tc.Exp+"0.0 Verify drained\n", b.String())
+	}
+}
+
+// String should list the procedure's steps as an indented outline.
+func TestProcedure_String(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.AddStep(func(step *Step) {
+			step.Name("checkDrained")
+			step.Short("Check that traffic is drained")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+	})
+
+	assert.Equal(
+		"root: Restart the service\n"+
+			"  drain: Drain traffic\n"+
+			"    checkDrained: Check that traffic is drained\n"+
+			"  restart: Restart the server\n",
+		pcd.String(),
+	)
+}
+
+// RenderAsciiDoc should render the procedure as AsciiDoc, with cross-references between steps.
+func TestProcedure_RenderAsciiDoc(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Fix the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.Long("Look it up in @@inventory@@.")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the host")
+		step.InputString("HostName", true)
+		step.ReferenceOutput("HostName")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderAsciiDoc(&b))
+	assert.Equal(`[[_fix-the-host]]
+= Fix the host
+
+[[_0-find-the-host]]
+== (0) Find the host
+
+`+"`root.findHost`"+` • <<_fix-the-host,Up>>
+
+Look it up in `+"`inventory`"+`.
+
+.Outputs
+* `+"`HostName`"+` (string): Name of the host
+
+[[_1-restart-the-host]]
+== (1) Restart the host
+
+`+"`root.restart`"+` • <<_fix-the-host,Up>>
+
+*References*: <<_0-find-the-host,`+"`HostName`"+`>>
+
+.Inputs
+* `+"`HostName`"+`
+`, b.String())
+}
+
+// normalizeWhitespace should strip trailing whitespace and collapse runs of blank lines.
+func TestNormalizeWhitespace(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		In  string
+		Out string
+	}
+
+	testCases := []testCase{
+		// Already normalized
+		testCase{
+			In:  "# Title\n\nBody\n",
+			Out: "# Title\n\nBody\n",
+		},
+		// Trailing spaces and tabs are stripped
+		testCase{
+			In:  "# Title  \n\t\nBody\t\n",
+			Out: "# Title\n\nBody\n",
+		},
+		// Two blank lines are kept, but three or more are collapsed to two
+		testCase{
+			In:  "a\n\n\nb\n\n\n\nc\n\n\n\n\n\nd\n",
+			Out: "a\n\n\nb\n\n\nc\n\n\nd\n",
+		},
+		// Exactly one trailing newline
+		testCase{
+			In:  "a",
+			Out: "a\n",
+		},
+		testCase{
+			In:  "a\n\n\n  \n",
+			Out: "a\n",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert.Equal(tc.Out, normalizeWhitespace(tc.In))
+	}
+}
+
+// RenderLegend should explain exactly the markers used in the procedure.
+func TestProcedure_RenderLegend(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart pods")
+
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderLegend(&b))
+	assert.Equal("", b.String())
+
+	pcd.AddStep(func(step *Step) {
+		step.Name("listPods")
+		step.Short("List affected pods")
+		step.OutputStringList("PodNames", "Affected pod names")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restartPod")
+		step.Short("Restart a pod")
+		step.RepeatFor("PodNames")
+		step.AddStep(func(step *Step) {
+			step.Name("checkPod")
+			step.Short("Check the pod")
+			step.ExpectedOutcome("The pod is running")
+		})
+	})
+
+	b.Reset()
+	assert.Nil(pcd.RenderLegend(&b))
+	assert.Equal(`**Legend**:
+
+  - **Expected**: The outcome you should see after performing the step
+  - **Repeat**: Perform the step, and its substeps, once for each item in the given list
+`, b.String())
+	assert.NotContains(b.String(), "**References**")
+}
+
+// firstSentence should return the first sentence of the first line.
+func TestFirstSentence(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		In  string
+		Out string
+	}
+
+	testCases := []testCase{
+		testCase{In: "", Out: ""},
+		testCase{In: "No period", Out: "No period"},
+		testCase{In: "One sentence.", Out: "One sentence."},
+		testCase{In: "First sentence. Second sentence.", Out: "First sentence."},
+		testCase{In: "First line\nsecond line. Blah.", Out: "First line"},
+		testCase{In: "Version 1.2 is out. Upgrade.", Out: "Version 1.2 is out."},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert.Equal(tc.Out, firstSentence(tc.In))
+	}
+}
+
+// With strict standins, Render should leave literal "@@" in prose alone while still converting code
+// spans.
+func TestProcedure_SetStrictStandins(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.SetStrictStandins(true)
+	pcd.AddStep(func(step *Step) {
+		step.Name("login")
+		step.Short("Log in")
+		step.Long(`Tell the user @@ at the prompt to run @@sudo -i@@. Their login is admin\@@host.`)
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "Tell the user @@ at the prompt to run `sudo -i`. Their login is admin@@host.")
+	assert.Contains(b.String(), "`root.login`")
+}
+
+// With SetStepComments, each section header should be preceded by a comment naming its step.
+func TestProcedure_SetStepComments(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.AddStep(func(step *Step) {
+			step.Name("checkDrained")
+			step.Short("Check that traffic is drained")
+		})
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.NotContains(b.String(), "<!--")
+
+	pcd.SetStepComments(true)
+	b.Reset()
+	assert.Nil(pcd.Render(&b))
+	assert.True(strings.HasPrefix(b.String(), "<!-- step: root -->\n# Restart the service\n"))
+	assert.Contains(b.String(), "\n\n<!-- step: root.drain -->\n## (0) Drain traffic\n")
+	assert.Contains(b.String(), "\n\n<!-- step: root.drain.checkDrained -->\n### (0.0) Check that traffic is drained\n")
+	assert.Equal(3, strings.Count(b.String(), "<!-- step: "))
+}
+
+// The variables table should list each output with the step that produces it and every step that
+// consumes it.
+func TestProcedure_SetVariablesTable(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Fix the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+		step.OutputString("Ticket", "Ticket number")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+		step.AddStep(func(step *Step) {
+			step.Name("verify")
+			step.Short("Verify the fix")
+			step.InputString("HostName", true)
+		})
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.NotContains(b.String(), "**Variables**")
+
+	pcd.SetVariablesTable(true)
+	b.Reset()
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), strings.Join([]string{
+		"**Variables**:",
+		"",
+		"| Name | Type | Produced by | Consumed by |",
+		"| --- | --- | --- | --- |",
+		"| `HostName` | string | [`root.findHost`](#0-find-the-host) | [`root.fixHost`](#1-fix-the-host), [`root.fixHost.verify`](#10-verify-the-fix) |",
+		"| `Ticket` | string | [`root.findHost`](#0-find-the-host) | none |",
+		"",
+		"- [Find the host](#0-find-the-host)",
+	}, "\n"))
+	assert.True(strings.Index(b.String(), "**Variables**") < strings.Index(b.String(), "## (0) Find the host"))
+}
+
+// SetRootName should rename the root step everywhere absolute names are used.
+func TestProcedure_SetRootName(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Deploy the app")
+	pcd.AddStep(func(step *Step) {
+		step.Name("build")
+		step.Short("Build the app")
+	})
+	pcd.SetRootName("deploy")
+
+	_, err := pcd.Check()
+	assert.Nil(err)
+
+	step, err := pcd.GetStepByName("deploy.build")
+	assert.Nil(err)
+	assert.Equal("deploy.build", step.AbsoluteName())
+	_, err = pcd.GetStepByName("root.build")
+	assert.NotNil(err)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "`deploy.build`")
+	assert.NotContains(b.String(), "root")
+
+	b.Reset()
+	assert.Nil(pcd.RenderStep(&b, "deploy.build"))
+	assert.Contains(b.String(), "Build the app")
+}
+
+// Check should flag an empty or invalid root name.
+func TestProcedure_SetRootName_Invalid(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	for _, name := range []string{"", "my.root", "my root"} {
+		pcd := NewProcedure()
+		pcd.Short("Deploy the app")
+		pcd.SetRootName(name)
+
+		problems, err := pcd.Check()
+		assert.NotNil(err)
+		assert.Equal(1, len(problems))
+	}
+}
+
+// Render should collapse chains of single-child steps when SetCollapseSingleChildChains is enabled.
+func TestProcedure_SetCollapseSingleChildChains(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("a")
+		step.Short("A")
+		step.Long("body of a")
+		step.AddStep(func(step *Step) {
+			step.Name("b")
+			step.Short("B")
+			step.AddStep(func(step *Step) {
+				step.Name("c")
+				step.Short("C")
+				step.Long("body of c")
+				step.AddStep(func(step *Step) {
+					step.Name("d")
+					step.Short("D")
+				})
+				step.AddStep(func(step *Step) {
+					step.Name("e")
+					step.Short("E")
+				})
+			})
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		// Only two steps long, so this isn't a chain
+		step.Name("f")
+		step.Short("F")
+		step.AddStep(func(step *Step) {
+			step.Name("g")
+			step.Short("G")
+		})
+	})
+	pcd.SetCollapseSingleChildChains(true)
+
+	// Children of the collapsed step should point at the collapsed step itself.
+	td := collapseChains(NewStepTemplateData(pcd.rootStep, nil, true))
+	collapsed := td.Children[0]
+	assert.Equal("A / B / C", collapsed.Title)
+	assert.Equal("A / B / C", collapsed.Children[0].Parent.Title)
+	assert.Equal(collapsed.Children, collapsed.Children[0].Parent.Children)
+	assert.Equal(td.Children, td.Children[0].Parent.Children)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Equal(`# Root step
+
+- [A / B / C](#0-a-b-c)
+    - [D](#00-d)
+    - [E](#01-e)
+- [F](#1-f)
+    - [G](#10-g)
+
+## (0) A / B / C
+
+`+"`root.a`, `root.a.b`, `root.a.b.c`"+`
+•
+[Up](#root-step)
+
+body of a
+
+body of c
+
+### (0.0) D
+
+`+"`root.a.b.c.d`"+`
+•
+[Up](#0-a-b-c)
+
+### (0.1) E
+
+`+"`root.a.b.c.e`"+`
+•
+[Up](#0-a-b-c)
+
+## (1) F
+
+`+"`root.f`"+`
+•
+[Up](#root-step)
+
+### (1.0) G
+
+`+"`root.f.g`"+`
+•
+[Up](#1-f)
+`, b.String())
+}
+
+// Render should render output references as links to the producing step.
+func TestProcedure_Render_ReferenceOutput(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("useHost")
+		step.Short("Look at the host")
+		// References may point forward in the procedure
+		step.ReferenceOutput("HostName")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("pickHost")
+		step.Short("Pick a host")
+		step.OutputString("HostName", "The host's name")
+		step.OutputString("HostIP", "The host's IP")
+		step.ReferenceOutput("HostIP")
+	})
+
+	_, err := pcd.Check()
+	assert.Nil(err)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "**References**: [`HostName`](#1-pick-a-host)\n")
+	assert.Contains(b.String(), "**References**: [`HostIP`](#1-pick-a-host)\n")
+
+	// When the producer isn't rendered, the reference isn't a link
+	b.Reset()
+	assert.Nil(pcd.RenderStep(&b, "root.useHost"))
+	assert.Contains(b.String(), "**References**: `HostName`")
+}
+
+// Check should flag a reference to an output that no step produces.
+func TestProcedure_Check_ReferenceOutput(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("useHost")
+		step.Short("Look at the host")
+		step.ReferenceOutput("HostName")
+	})
+
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{"Reference 'HostName' of step 'root.useHost' does not refer to an output from any step"}, problems)
+}
+
+// When steps have inputs but no step has outputs, Check should lead with a summary problem.
+func TestProcedure_Check_NoOutputs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("checkHost")
+		step.Short("Check the host")
+		step.InputString("HostName", true)
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+		step.InputString("Region", false)
+	})
+
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal(4, len(problems))
+	assert.Equal(
+		"Some steps have inputs, but no step has any outputs; did you forget to declare outputs with Step.Output*?",
+		problems[0],
+	)
+}
+
+// CheckOrdering should report a consumer that's been moved ahead of its producer.
+func TestProcedure_CheckOrdering(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Fix the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+		step.InputString("Unknown", false)
+	})
+	assert.Equal([]string{}, pcd.CheckOrdering())
+
+	// Swap the two steps, as sorting them by name would.
+	children := pcd.rootStep.children
+	children[0], children[1] = children[1], children[0]
+	assert.Equal(
+		[]string{"Step 'root.fixHost' consumes output 'HostName', but the step that produces it, 'root.findHost', comes after it"},
+		pcd.CheckOrdering(),
+	)
+}
+
+// Check should report inputs that disagree on whether the output they refer to is required.
+func TestProcedure_Check_InconsistentRequired(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("checkHost")
+		step.Short("Check the host")
+		step.InputString("HostName", false)
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+	})
+
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{"Input 'HostName' is required by step 'root.fixHost' but optional for step 'root.checkHost'"}, problems)
+}
+
+// Check should report a step that takes an input produced by one of its own descendants.
+func TestProcedure_Check_DescendantProducer(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+		step.AddStep(func(step *Step) {
+			step.Name("investigate")
+			step.Short("Investigate")
+			step.AddStep(func(step *Step) {
+				step.Name("findHost")
+				step.Short("Find the host")
+				step.OutputString("HostName", "Name of the host")
+			})
+		})
+	})
+
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{"Input 'HostName' of step 'root.fixHost' is produced by its descendant 'root.fixHost.investigate.findHost', which executes after it"}, problems)
+}
+
+// Check should report a required input whose producing step might not execute, unless the input is
+// consumed within the same repetition.
+func TestProcedure_Check_ConditionalProducer(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		// Whether the consuming step is a child of the repeating step. Otherwise it's a child of
+		// the root.
+		ConsumerInside bool
+		Problems       []string
+	}
+
+	testCases := []testCase{
+		testCase{
+			ConsumerInside: false,
+			Problems: []string{
+				"Required input 'PodStatus' of step 'root.report' is produced by step 'root.restartPod.checkPod', which might not execute because step 'root.restartPod' repeats for each item in 'PodNames'",
+			},
+		},
+		testCase{
+			ConsumerInside: true,
+			Problems:       []string{},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert := assert.New(t)
+
+		report := func(step *Step) {
+			step.Name("report")
+			step.Short("Report the pod status")
+			step.InputString("PodStatus", true)
+		}
+
+		pcd := NewProcedure()
+		pcd.Short("Restart pods")
+		pcd.AddStep(func(step *Step) {
+			step.Name("listPods")
+			step.Short("List affected pods")
+			step.OutputStringList("PodNames", "Affected pod names")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("restartPod")
+			step.Short("Restart a pod")
+			step.RepeatFor("PodNames")
+			step.AddStep(func(step *Step) {
+				step.Name("checkPod")
+				step.Short("Check the pod")
+				step.OutputString("PodStatus", "Status of the pod")
+			})
+			if tc.ConsumerInside {
+				step.AddStep(report)
+			}
+		})
+		if !tc.ConsumerInside {
+			pcd.AddStep(report)
+		}
+
+		problems, _ := pcd.Check()
+		assert.Equal(tc.Problems, problems)
+	}
+}
+
+// Inputs should be prompted for in the order given to PromptOrder, followed by the rest in
+// declaration order.
+func TestProcedure_ExecuteStep_PromptOrder(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Deploy")
+	pcd.AddStep(func(step *Step) {
+		step.Name("pickTarget")
+		step.Short("Pick the deploy target")
+		step.OutputString("Cluster", "Cluster name")
+		step.OutputString("Zone", "Zone name")
+		step.OutputString("Region", "Region name")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("deploy")
+		step.Short("Deploy to the target")
+		step.InputString("Cluster", true)
+		step.InputString("Zone", true)
+		step.InputString("Region", true)
+		step.PromptOrder("Region", "Cluster")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		"us-east",
+		"prod-1",
+		"us-east-1a",
+		"",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteStep("root.deploy"))
+	out := stdout.String()
+	region := strings.Index(out, "Value for input 'Region'")
+	cluster := strings.Index(out, "Value for input 'Cluster'")
+	zone := strings.Index(out, "Value for input 'Zone'")
+	assert.True(region != -1 && region < cluster && cluster < zone, out)
+	assert.Contains(out, "  - Region: us-east\n")
+	assert.Contains(out, "  - Cluster: prod-1\n")
+	assert.Contains(out, "  - Zone: us-east-1a\n")
+}
+
+// When prompting for an input with no value, Execute should name the step that normally produces
+// it, if there is one.
+func TestProcedure_InputValue_Producer(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		// Whether the procedure has a step that produces the input
+		HasProducer bool
+		// Whether the prompt should include the producer note
+		NoteExp bool
+	}
+
+	testCases := []testCase{
+		testCase{HasProducer: true, NoteExp: true},
+		testCase{HasProducer: false, NoteExp: false},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert := assert.New(t)
+
+		pcd := NewProcedure()
+		pcd.Short("Fix the host")
+		if tc.HasProducer {
+			pcd.AddStep(func(step *Step) {
+				step.Name("findHost")
+				step.Short("Find the host")
+				step.OutputString("HostName", "Name of the host")
+			})
+		}
+		pcd.stdin = strings.NewReader("db-1\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+		pcd.startRun(context.Background())
+
+		value, err := pcd.inputValue("HostName", "string", true)
+		assert.Nil(err)
+		assert.Equal("db-1", value)
+		assert.Contains(stdout.String(), "Value for input 'HostName'")
+		if tc.NoteExp {
+			assert.Contains(stdout.String(), "Value for input 'HostName' (normally produced by step 'root.findHost'): ")
+		} else {
+			assert.NotContains(stdout.String(), "normally produced by")
+		}
+	}
+}
+
+// Input with CRLF line endings should be handled the same as input with LF line endings.
+func TestProcedure_Execute_CRLF(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	script := []string{
+		// root
+		"note started from a Windows terminal",
+		"",
+		// root.listHosts
+		"",
+		"web-1",
+		"web-2",
+		"",
+		// root.middle
+		"skipto root.restart",
+		// root.restart
+		"",
+	}
+
+	run := func(lineEnding string) (string, *RunReport) {
+		pcd := NewProcedure()
+		pcd.Short("Restart hosts")
+		pcd.AddStep(func(step *Step) {
+			step.Name("listHosts")
+			step.Short("List the hosts")
+			step.OutputStringList("HostNames", "Names of the hosts")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("middle")
+			step.Short("Something to skip past")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("other")
+			step.Short("Something else to skip past")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("restart")
+			step.Short("Restart the hosts")
+		})
+
+		pcd.stdin = strings.NewReader(strings.Join(script, lineEnding) + lineEnding)
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+		assert.Nil(pcd.Execute())
+		return stdout.String(), pcd.LastRunReport()
+	}
+
+	lfOut, lfReport := run("\n")
+	crlfOut, crlfReport := run("\r\n")
+	assert.Equal(lfOut, crlfOut)
+	assert.NotContains(crlfOut, "\r")
+	assert.Contains(crlfOut, "Skipping step 'root.other' on the way to 'root.restart'")
+	assert.Equal(map[string]string{"HostNames": "web-1\nweb-2"}, crlfReport.Values)
+	assert.Equal([]string{"started from a Windows terminal"}, crlfReport.Steps[0].Notes)
+	assert.Equal(len(lfReport.Steps), len(crlfReport.Steps))
+}
+
+// With quiet skips, the steps skipped on the way to a skipto target should be summarized in a
+// single line instead of being listed one by one.
+func TestProcedure_Execute_QuietSkips(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		Quiet       bool
+		Contains    []string
+		NotContains []string
+	}
+
+	testCases := []testCase{
+		testCase{
+			Quiet: false,
+			Contains: []string{
+				"Skipping step 'root.drain' on the way to 'root.verify'\n",
+				"Skipping step 'root.drain.one' on the way to 'root.verify'\n",
+				"Skipping step 'root.restart' on the way to 'root.verify'\n",
+			},
+			NotContains: []string{"Skipped "},
+		},
+		testCase{
+			Quiet:       true,
+			Contains:    []string{"Skipped 3 steps on the way to 'root.verify'\n"},
+			NotContains: []string{"Skipping step"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Restart the service")
+		pcd.SetQuietSkips(tc.Quiet)
+		pcd.AddStep(func(step *Step) {
+			step.Name("drain")
+			step.Short("Drain traffic")
+			step.AddStep(func(step *Step) {
+				step.Name("one")
+				step.Short("Drain the first host")
+			})
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("restart")
+			step.Short("Restart the service")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("verify")
+			step.Short("Verify the service")
+		})
+
+		pcd.stdin = strings.NewReader(strings.Join([]string{
+			// root
+			"skipto root.verify",
+			// root.verify
+			"",
+		}, "\n") + "\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		assert.Nil(pcd.Execute())
+		for _, s := range tc.Contains {
+			assert.Contains(stdout.String(), s)
+		}
+		for _, s := range tc.NotContains {
+			assert.NotContains(stdout.String(), s)
+		}
+		assert.Equal(5, len(pcd.LastRunReport().Steps))
+	}
+}
+
+// With quiet skips, the summary should use the singular for one step, and should still be printed
+// if execution ends before the skipto target is reached.
+func TestProcedure_Execute_QuietSkips_Unreached(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.SetQuietSkips(true)
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.AddStep(func(step *Step) {
+			step.Name("one")
+			step.Short("Drain the first host")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("verify")
+		step.Short("Verify the service")
+	})
+
+	pcd.stdin = strings.NewReader("skipto root.verify\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.ExecuteStep("root.drain"))
+	assert.Contains(stdout.String(), "Skipped 1 step on the way to 'root.verify', which was never reached\nDone.\n")
+}
+
+// A RepeatFor step that is the target of a skipto should still repeat once per item.
+func TestProcedure_ExecuteStep_RepeatFor_SkipTo(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart pods")
+	pcd.AddStep(func(step *Step) {
+		step.Name("listPods")
+		step.Short("List affected pods")
+		step.OutputStringList("PodNames", "Affected pod names")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("middle")
+		step.Short("Something to skip past")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("other")
+		step.Short("Something else to skip past")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restartPod")
+		step.Short("Restart a pod")
+		step.RepeatFor("PodNames")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.listPods
+		"",
+		"pod-a",
+		"pod-b",
+		"",
+		// root.middle
+		"skipto root.restartPod",
+		// root.restartPod, once per pod
+		"",
+		"",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	out := stdout.String()
+	assert.Contains(out, "Skipping step 'root.other' on the way to 'root.restartPod'")
+	assert.Contains(out, "PodNames: pod-a (item 1 of 2)")
+	assert.Contains(out, "PodNames: pod-b (item 2 of 2)")
+	assert.Equal(2, strings.Count(out, "Restart a pod"))
+	assert.Contains(out, "Done.")
+}
+
+// Execute should re-prompt until an int output is given an integer value.
+func TestProcedure_Execute_IntOutput(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Count things")
+	pcd.AddStep(func(step *Step) {
+		step.Name("count")
+		step.Short("Count the things")
+		step.Output("int", "Count", "The number of things")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.count
+		"",
+		"",
+		"lots",
+		"12",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "A value is required\n")
+	assert.Contains(stdout.String(), "Value must be an integer\n")
+	assert.Equal(map[string]string{"Count": "12"}, pcd.LastRunReport().Values)
+}
+
+// An output's example value should be shown when the user is prompted for the output.
+func TestProcedure_Execute_OutputExample(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Find the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("find")
+		step.Short("Find the host")
+		step.OutputStringExample("HostName", "Name of the host", "db-01")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.find
+		"",
+		"db-02",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "Name of the host (HostName, e.g. db-01): ")
+	assert.Equal(map[string]string{"HostName": "db-02"}, pcd.LastRunReport().Values)
+}
+
+// Answering "no" to an expected outcome's confirmation should record a discrepancy in the report.
+func TestProcedure_ExpectedOutcome_Mismatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Flip the switches")
+	pcd.AddStep(func(step *Step) {
+		step.Name("first")
+		step.Short("Flip the first switch")
+		step.ExpectedOutcome("The light turns green")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("second")
+		step.Short("Flip the second switch")
+		step.ExpectedOutcome("The light turns blue")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.first
+		"",
+		"y",
+		// root.second
+		"",
+		"maybe",
+		"n",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "Expected: The light turns green")
+	assert.Contains(stdout.String(), "Did the outcome match what was expected? [y/n]: ")
+
+	rpt := pcd.LastRunReport()
+	assert.Equal(3, len(rpt.Steps))
+	assert.False(rpt.Steps[1].OutcomeMismatch)
+	assert.True(rpt.Steps[2].OutcomeMismatch)
+
+	b, err := json.Marshal(rpt)
+	assert.Nil(err)
+	assert.Equal(1, strings.Count(string(b), `"outcomeMismatch":true`))
+}
+
+// A skipped step should still show its expected outcome, but shouldn't ask for confirmation.
+func TestProcedure_ExpectedOutcome_Skip(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Flip the switches")
+	pcd.AddStep(func(step *Step) {
+		step.Name("first")
+		step.Short("Flip the first switch")
+		step.ExpectedOutcome("The light turns green")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.first
+		"skip",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "Flip the first switch\n\nExpected: The light turns green")
+	assert.NotContains(stdout.String(), "[y/n]")
+	assert.True(pcd.LastRunReport().Steps[1].Skipped)
+	assert.False(pcd.LastRunReport().Steps[1].OutcomeMismatch)
+}
+
+// ExecuteContext should stop and write the state file when its context is cancelled.
+func TestProcedure_ExecuteContext_Interrupted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.AddStep(func(step *Step) {
+		step.Name("first")
+		step.Short("The first step")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("second")
+		step.Short("The second step")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("third")
+		step.Short("The third step")
+	})
+
+	stateFile := path.Join(t.TempDir(), "state.json")
+	pcd.SetStateFile(stateFile)
+
+	// Input for root and root.first arrives, but then the user hits Ctrl-C at root.second instead
+	// of entering anything. Each write to the pipe is a separate read, so the third read is the one
+	// for root.second.
+	stdinReader, stdinWriter := io.Pipe()
+	reads := make(chan struct{}, 10)
+	pcd.stdin = &signalingReader{r: stdinReader, reads: reads}
+	var stdout bytes.Buffer
+	pcd.stdout = &syncWriter{w: &stdout}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		stdinWriter.Write([]byte("\n"))
+		stdinWriter.Write([]byte("\n"))
+		for i := 0; i < 3; i++ {
+			<-reads
+		}
+		cancel()
+	}()
+
+	err := pcd.ExecuteContext(ctx)
+	assert.True(errors.Is(err, ErrInterrupted))
+	assert.Contains(stdout.String(), "Interrupted at step 'root.second'")
+	assert.NotContains(stdout.String(), "The third step")
+
+	state, err := ioutil.ReadFile(stateFile)
+	assert.Nil(err)
+	var report map[string]interface{}
+	assert.Nil(json.Unmarshal(state, &report))
+	assert.Contains(string(state), `"name": "root.first"`)
+	assert.Contains(report["error"], "Interrupted at step 'root.second'")
+
+	// The line that the interrupted read was waiting for goes to the next execution.
+	done := make(chan error, 1)
+	go func() {
+		done <- pcd.ExecuteStepOnly("root.second")
+	}()
+	stdinWriter.Write([]byte("\n"))
+	select {
+	case err := <-done:
+		assert.Nil(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("input read after the interruption was lost")
+	}
+}
+
+// A signalingReader sends on reads each time it's read from.
+type signalingReader struct {
+	r     io.Reader
+	reads chan struct{}
+}
+
+// Read reads from the underlying reader, after signaling the read.
+func (sr *signalingReader) Read(p []byte) (int, error) {
+	sr.reads <- struct{}{}
+	return sr.r.Read(p)
+}
+
+// LastRunValues should return the values collected in the last execution, leaving out secrets.
+func TestProcedure_LastRunValues(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	assert.Nil(pcd.LastRunValues())
+
+	pcd.Short("Rotate credentials")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+		step.OutputStringSecret("Password", "New password")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("findAdmin")
+		step.Short("Find the admin user")
+		step.InputString("HostName", true)
+		step.OutputString("AdminName", "Name of the admin user")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.findHost
+		"",
+		"db-1",
+		"hunter2",
+		// root.findAdmin
+		"",
+		"alice",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Equal(map[string]string{"HostName": "db-1", "AdminName": "alice"}, pcd.LastRunValues())
+}
+
+// Execute should ask about each prerequisite separately and abort on the first one that isn't met.
+func TestProcedure_Prerequisite(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		// The answers to the prerequisite questions
+		Answers []string
+		// Whether execution should be aborted
+		AbortExp bool
+		// The number of prerequisite questions that should be asked
+		AskedExp int
+	}
+
+	testCases := []testCase{
+		testCase{
+			Answers:  []string{"y", "yes", "y"},
+			AbortExp: false,
+			AskedExp: 3,
+		},
+		testCase{
+			Answers:  []string{"y", "n"},
+			AbortExp: true,
+			AskedExp: 2,
+		},
+		testCase{
+			Answers:  []string{"no"},
+			AbortExp: true,
+			AskedExp: 1,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert := assert.New(t)
+
+		pcd := NewProcedure()
+		pcd.Short("Fail over the database")
+		pcd.Prerequisite("You have a change ticket")
+		pcd.Prerequisite("The replica is caught up")
+		pcd.Prerequisite("The on-call engineer knows you're starting")
+
+		script := append([]string{}, tc.Answers...)
+		if !tc.AbortExp {
+			// root
+			script = append(script, "")
+		}
+		pcd.stdin = strings.NewReader(strings.Join(script, "\n") + "\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		err := pcd.Execute()
+		assert.Equal(tc.AbortExp, err != nil)
+		assert.Equal(tc.AskedExp, strings.Count(stdout.String(), "Is this prerequisite met?"))
+		if tc.AbortExp {
+			assert.NotContains(stdout.String(), "# Fail over the database")
+			assert.Contains(err.Error(), "prerequisite was not met")
+			assert.True(errors.Is(err, ErrAborted))
+		} else {
+			assert.Contains(stdout.String(), "# Fail over the database")
+		}
+	}
+}
+
+// With SetMenuPrompt, numbered choices should map to the same actions as the usual prompt.
+func TestProcedure_SetMenuPrompt(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		// What the user enters at the prompts
+		Script []string
+		// Strings that should appear in the output
+		Shown []string
+		// Section headers of steps that should not be shown to the user
+		NotShown []string
+		// Whether Execute should return an error
+		ErrorExp bool
+	}
+
+	testCases := []testCase{
+		// Proceed, by number and by default
+		testCase{
+			Script:   []string{"1", "", "1", "1"},
+			Shown:    []string{"## (0) Step A", "### (0.0) Step A1", "## (1) Step B"},
+			NotShown: []string{},
+		},
+		// Skip a step and its descendants, after an invalid choice
+		testCase{
+			Script:   []string{"1", "9", "2", "1"},
+			Shown:    []string{"## (0) Step A", "Invalid choice", "## (1) Step B"},
+			NotShown: []string{"### (0.0) Step A1"},
+		},
+		// Skip to a step
+		testCase{
+			Script:   []string{"3", "root.b", "1"},
+			Shown:    []string{"## (1) Step B"},
+			NotShown: []string{"## (0) Step A"},
+		},
+		// Quit
+		testCase{
+			Script:   []string{"1", "4"},
+			Shown:    []string{"## (0) Step A"},
+			NotShown: []string{"### (0.0) Step A1", "## (1) Step B"},
+			ErrorExp: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert := assert.New(t)
+
+		pcd := NewProcedure()
+		pcd.Short("Root step")
+		pcd.SetMenuPrompt(true)
+		pcd.AddStep(func(step *Step) {
+			step.Name("a")
+			step.Short("Step A")
+			step.AddStep(func(step *Step) {
+				step.Name("a1")
+				step.Short("Step A1")
+			})
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("b")
+			step.Short("Step B")
+		})
+
+		pcd.stdin = strings.NewReader(strings.Join(tc.Script, "\n") + "\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		err := pcd.Execute()
+		assert.Equal(tc.ErrorExp, err != nil)
+		assert.Equal(tc.ErrorExp, errors.Is(err, ErrAborted))
+		assert.Contains(stdout.String(), "  4) Quit\nChoice [1]: ")
+		assert.NotContains(stdout.String(), "[Enter] to proceed")
+		for _, s := range tc.Shown {
+			assert.Contains(stdout.String(), s)
+		}
+		for _, s := range tc.NotShown {
+			assert.NotContains(stdout.String(), s)
+		}
+	}
+}
+
+// A branching step should execute the child for the user's answer and skip the other.
+func TestProcedure_Execute_Branch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		Answer   string
+		Taken    string
+		NotTaken string
+	}
+
+	testCases := []testCase{
+		testCase{Answer: "y", Taken: "root.deploy.proceed", NotTaken: "root.deploy.rollback"},
+		testCase{Answer: "n", Taken: "root.deploy.rollback", NotTaken: "root.deploy.proceed"},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Deploy the app")
+		pcd.AddStep(func(step *Step) {
+			step.Name("deploy")
+			step.Short("Deploy the new version")
+			step.Branch("Did the deploy succeed?", "proceed", "rollback")
+			step.AddStep(func(step *Step) {
+				step.Name("rollback")
+				step.Short("Roll back the deploy")
+			})
+			step.AddStep(func(step *Step) {
+				step.Name("proceed")
+				step.Short("Announce the deploy")
+			})
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("cleanUp")
+			step.Short("Clean up")
+		})
+
+		pcd.stdin = strings.NewReader(strings.Join([]string{
+			// root
+			"",
+			// root.deploy
+			"",
+			"maybe",
+			tc.Answer,
+			// the branch taken
+			"",
+			// root.cleanUp
+			"",
+		}, "\n") + "\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		assert.Nil(pcd.Execute())
+		assert.Contains(stdout.String(), "Did the deploy succeed? [y/n]: ")
+		assert.Contains(stdout.String(), "Please enter 'y' or 'n'\n")
+		assert.Contains(stdout.String(), fmt.Sprintf("Taking branch '%s'\n", tc.Taken))
+		assert.Contains(stdout.String(), fmt.Sprintf("Skipping step '%s' and its descendants, since its branch wasn't taken\n", tc.NotTaken))
+		assert.Contains(stdout.String(), "Clean up")
+
+		skipped := make(map[string]bool)
+		for _, stepReport := range pcd.LastRunReport().Steps {
+			skipped[stepReport.Name] = stepReport.Skipped
+		}
+		assert.Equal(map[string]bool{
+			"root":         false,
+			"root.deploy":  false,
+			tc.Taken:       false,
+			tc.NotTaken:    true,
+			"root.cleanUp": false,
+		}, skipped)
+	}
+}
+
+// ExecuteUntil should stop once the branch not taken is skipped, if the stop step is in it.
+func TestProcedure_ExecuteUntil_BranchNotTaken(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Deploy the app")
+	pcd.AddStep(func(step *Step) {
+		step.Name("deploy")
+		step.Short("Deploy the new version")
+		step.Branch("Did the deploy succeed?", "proceed", "rollback")
+		step.AddStep(func(step *Step) {
+			step.Name("proceed")
+			step.Short("Announce the deploy")
+		})
+		step.AddStep(func(step *Step) {
+			step.Name("rollback")
+			step.Short("Roll back the deploy")
+			step.AddStep(func(step *Step) {
+				step.Name("verify")
+				step.Short("Verify the rollback")
+			})
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("cleanUp")
+		step.Short("Clean up")
+	})
+
+	for _, stopStep := range []string{"root.deploy.rollback", "root.deploy.rollback.verify"} {
+		pcd.stdin = strings.NewReader(strings.Join([]string{
+			// root
+			"",
+			// root.deploy
+			"",
+			"y",
+			// root.deploy.proceed
+			"",
+		}, "\n") + "\n")
+		var stdout bytes.Buffer
+		pcd.stdout = &stdout
+
+		assert.Nil(pcd.ExecuteUntil("root", stopStep))
+		assert.Contains(stdout.String(), "Announce the deploy")
+		assert.Contains(stdout.String(), fmt.Sprintf("Stopped after %s\n", stopStep))
+		assert.NotContains(stdout.String(), "Clean up")
+		assert.NotContains(stdout.String(), "Done.")
+	}
+}
+
+// A branch should be noted in the rendered Markdown with links to both branches, and Check should
+// reject a branch that doesn't name two different children.
+func TestProcedure_Branch_RenderAndCheck(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Deploy the app")
+	pcd.AddStep(func(step *Step) {
+		step.Name("deploy")
+		step.Short("Deploy the new version")
+		step.Branch("Did the deploy succeed?", "proceed", "rollback")
+		step.AddStep(func(step *Step) {
+			step.Name("rollback")
+			step.Short("Roll back the deploy")
+		})
+		step.AddStep(func(step *Step) {
+			step.Name("proceed")
+			step.Short("Announce the deploy")
+		})
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "**Branch**: Did the deploy succeed? If yes, go to [`root.deploy.proceed`](#01-announce-the-deploy); if no, go to [`root.deploy.rollback`](#00-roll-back-the-deploy).")
+	assert.Contains(b.String(), "### (0.0) Roll back the deploy")
+	assert.Contains(b.String(), "### (0.1) Announce the deploy")
+
+	pcd.AddStep(func(step *Step) {
+		step.Name("verify")
+		step.Short("Verify the deploy")
+		step.Branch("Is it working?", "done", "done")
+		step.AddStep(func(step *Step) {
+			step.Name("done")
+			step.Short("Finish up")
+		})
+	})
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{
+		"Branch of step 'root.verify' has the same step, 'done', for both answers",
+	}, problems)
+
+	pcd.rootStep.children[1].Branch("Is it working?", "done", "giveUp")
+	problems, err = pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{
+		"Branch of step 'root.verify' refers to 'giveUp', which is not a child of the step",
+	}, problems)
+}
+
+// With InputStyleTable, each step's inputs should be rendered as a table described by the outputs
+// they refer to.
+func TestProcedure_SetInputStyle(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Fix the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "**Inputs**:\n\n  - `HostName`")
+
+	pcd.SetInputStyle(InputStyleTable)
+	b.Reset()
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), strings.Join([]string{
+		"**Inputs**:",
+		"",
+		"| Name | Type | Required | Description |",
+		"| --- | --- | --- | --- |",
+		"| `HostName` | string | yes | Name of the host |",
+	}, "\n"))
+	assert.NotContains(b.String(), "  - `HostName`\n")
+}
+
+// RenderStep and ExecuteStep should explain what to do when given an empty step name.
+func TestProcedure_EmptyStepName(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Deploy the app")
+	pcd.SetRootName("deploy")
+	pcd.AddStep(func(step *Step) {
+		step.Name("build")
+		step.Short("Build the app")
+	})
+	pcd.stdin = strings.NewReader("")
+	pcd.stdout = &bytes.Buffer{}
+
+	for i, name := range []string{"", " ", "\t\n"} {
+		t.Logf("test case %d", i)
+
+		var b bytes.Buffer
+		err := pcd.RenderStep(&b, name)
+		assert.EqualError(err, "Step name must not be empty; use 'deploy' for the whole procedure")
+		assert.Equal("", b.String())
+
+		err = pcd.ExecuteStep(name)
+		assert.EqualError(err, "Step name must not be empty; use 'deploy' for the whole procedure")
+	}
+}
+
+// ExecuteSequence should execute exactly the given steps, in the given order, carrying values from
+// one to the next.
+func TestProcedure_ExecuteSequence(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Maintain the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+		step.AddStep(func(step *Step) {
+			step.Name("ping")
+			step.Short("Ping the host")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("reportHost")
+		step.Short("Report the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Repair the host")
+		step.InputString("HostName", true)
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root.findHost
+		"",
+		"web-1",
+		// root.fixHost
+		"",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.ExecuteSequence([]string{"root.findHost", "root.fixHost"}))
+	assert.True(strings.Index(stdout.String(), "Find the host") < strings.Index(stdout.String(), "Repair the host"))
+	assert.Contains(stdout.String(), "web-1")
+	assert.NotContains(stdout.String(), "Ping the host")
+	assert.NotContains(stdout.String(), "Report the host")
+	assert.NotContains(stdout.String(), "Maintain the host")
+	assert.Equal(2, len(pcd.LastRunReport().Steps))
+
+	assert.NotNil(pcd.ExecuteSequence([]string{}))
+	assert.NotNil(pcd.ExecuteSequence([]string{"root.findHost", "root.nonexistent"}))
+}
+
+// An optional output should be prompted for, and accept an empty value without re-prompting.
+func TestProcedure_Execute_OptionalOutput(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the server")
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+		step.OutputStringOptional("Ticket", "Ticket number, if there is one")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("report")
+		step.Short("Report the restart")
+		step.InputString("Ticket", false)
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.restart
+		"",
+		"",
+		"",
+		"web-1",
+		// root.report
+		"",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	assert.Equal(1, strings.Count(stdout.String(), "Ticket number, if there is one (Ticket): "))
+	// Only the empty HostName should have been rejected.
+	assert.Equal(1, strings.Count(stdout.String(), "A value is required\n"))
+	assert.Equal(map[string]string{"Ticket": "", "HostName": "web-1"}, pcd.LastRunValues())
+}
+
+// Check should flag a required input that refers to an optional output.
+func TestProcedure_Check_OptionalOutput(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the server")
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+		step.OutputStringOptional("Ticket", "Ticket number, if there is one")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("closeTicket")
+		step.Short("Close the ticket")
+		step.InputString("Ticket", true)
+	})
+
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{
+		"Required input 'Ticket' of step 'root.closeTicket' refers to optional output of step 'root.restart', which may be empty",
+	}, problems)
+}
+
+// PromptCommands should describe the standard prompt commands, and the prompt's help message should
+// list them.
+func TestProcedure_PromptCommands(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the server")
+
+	commands := pcd.PromptCommands()
+	names := make([]string, 0)
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+		assert.NotEqual("", cmd.Usage)
+		assert.NotEqual("", cmd.Description)
+	}
+	assert.Equal([]string{"proceed", "skip", "skipto", "note", "help"}, names)
+
+	pcd.stdin = strings.NewReader("help\n\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	for _, cmd := range commands {
+		assert.Contains(stdout.String(), cmd.Usage)
+		assert.Contains(stdout.String(), cmd.Description)
+	}
+	assert.Contains(stdout.String(), "skipto STEP     Skip to the given step by absolute name\n")
+}
+
+// With the glossary enabled, the rendered Markdown should end with a glossary of outputs, and
+// each step's inputs and outputs should link to their glossary entries.
+func TestProcedure_SetGlossary(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Fix the host")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "Name of the host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("fixHost")
+		step.Short("Fix the host")
+		step.InputString("HostName", true)
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.NotContains(b.String(), "Glossary")
+
+	pcd.SetGlossary(true)
+	b.Reset()
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "**Outputs**:\n\n  - [`HostName`](#hostname) (string): Name of the host\n")
+	assert.Contains(b.String(), "**Inputs**:\n\n  - [`HostName`](#hostname)\n")
+	assert.True(strings.HasSuffix(b.String(), strings.Join([]string{
+		"## Glossary",
+		"",
+		"### `HostName`",
+		"",
+		"Name of the host",
+		"",
+		"  - **Type**: string",
+		"  - **Produced by**: [`root.findHost`](#0-find-the-host)",
+		"  - **Consumed by**: [`root.fixHost`](#1-fix-the-host)",
+		"",
+	}, "\n")))
+
+	// An input whose producer isn't being rendered has no glossary entry to link to.
+	b.Reset()
+	assert.Nil(pcd.RenderStep(&b, "root.fixHost"))
+	assert.Contains(b.String(), "**Inputs**:\n\n  - `HostName`")
+	assert.NotContains(b.String(), "Glossary")
+
+	// Glossary links should also apply to the inputs table.
+	pcd.SetInputStyle(InputStyleTable)
+	b.Reset()
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "| [`HostName`](#hostname) | string | yes | Name of the host |")
+}
+
+// A step's rollback instructions should be noted in its section, and RenderRollback should list
+// them in reverse order.
+func TestProcedure_RenderRollback(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Upgrade the database")
+	pcd.AddStep(func(step *Step) {
+		step.Name("snapshot")
+		step.Short("Take a snapshot")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("upgrade")
+		step.Short("Upgrade the database")
+		step.Rollback(`
+			Downgrade the database with @@db-downgrade@@.
+		`)
+		step.AddStep(func(step *Step) {
+			step.Name("migrate")
+			step.Short("Migrate the schema")
+			step.Rollback("Run the down migrations.")
+		})
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "## (1) Upgrade the database\n\n`root.upgrade`\n•\n[Up](#upgrade-the-database)\n\n*Rollback*: Downgrade the database with `db-downgrade`.\n")
+	assert.Contains(b.String(), "*Rollback*: Run the down migrations.")
+	assert.Equal(2, strings.Count(b.String(), "*Rollback*"))
+
+	b.Reset()
+	assert.Nil(pcd.RenderRollback(&b))
+	assert.Equal(`# Rollback: Upgrade the database
+
+## Roll back (1.0) Migrate the schema
+
+`+"`root.upgrade.migrate`"+`
+
+Run the down migrations.
+
+## Roll back (1) Upgrade the database
+
+`+"`root.upgrade`"+`
+
+Downgrade the database with `+"`db-downgrade`"+`.
+`, b.String())
+}
+
+// ExecuteWithBaseline should flag each response that differs from the baseline transcript.
+func TestProcedure_ExecuteWithBaseline(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	baseline := strings.Join([]string{
+		// root
+		"",
+		// root.findHost
+		"",
+		"web-1",
+		// root.fixHost
+		"",
+	}, "\r\n") + "\r\n"
+
+	type testCase struct {
+		Responses  []string
+		Deviations []string
+	}
+
+	testCases := []testCase{
+		testCase{
+			Responses:  []string{"", "", "web-1", ""},
+			Deviations: []string{},
+		},
+		testCase{
+			Responses:  []string{"", "", "web-2", ""},
+			Deviations: []string{"Response 3: expected 'web-1', got 'web-2'"},
+		},
+		testCase{
+			Responses: []string{"", "note checked twice", "", "web-1", ""},
+			Deviations: []string{
+				"Response 2: expected '', got 'note checked twice'",
+				"Response 3: expected 'web-1', got ''",
+				"Response 4: expected '', got 'web-1'",
+				"Response 5: got '', which isn't in the baseline",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Fix the host")
+		pcd.AddStep(func(step *Step) {
+			step.Name("findHost")
+			step.Short("Find the host")
+			step.OutputString("HostName", "Name of the host")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("fixHost")
+			step.Short("Fix the host")
+			step.InputString("HostName", true)
+		})
+
+		pcd.stdin = strings.NewReader(strings.Join(tc.Responses, "\n") + "\n")
+		pcd.stdout = &bytes.Buffer{}
+		deviations, err := pcd.ExecuteWithBaseline(strings.NewReader(baseline))
+		assert.Nil(err)
+		assert.Equal(tc.Deviations, deviations)
+	}
+}
+
+// ExecuteWithBaseline should compare secret values without showing them in the deviations.
+func TestProcedure_ExecuteWithBaseline_Secret(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Log in")
+	pcd.AddStep(func(step *Step) {
+		step.Name("getPassword")
+		step.Short("Get the password")
+		step.OutputStringSecret("Password", "The password")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{"", "", "hunter3"}, "\n") + "\n")
+	pcd.stdout = &bytes.Buffer{}
+	deviations, err := pcd.ExecuteWithBaseline(strings.NewReader("\n\nhunter2\n"))
+	assert.Nil(err)
+	assert.Equal([]string{"Response 3: expected 'REDACTED', got 'REDACTED'"}, deviations)
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{"", "", "hunter2"}, "\n") + "\n")
+	deviations, err = pcd.ExecuteWithBaseline(strings.NewReader("\n\nhunter2\n"))
+	assert.Nil(err)
+	assert.Equal([]string{}, deviations)
+}
+
+// A step's links should be rendered as a "See also" list in Markdown and shown with their URLs
+// during execution.
+func TestProcedure_Link(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the service")
+		step.Link("Runbook", "https://wiki.example.com/runbook")
+		step.Link("Dashboard", "https://grafana.example.com/d/service")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "**See also**:\n\n  - [Runbook](https://wiki.example.com/runbook)\n  - [Dashboard](https://grafana.example.com/d/service)")
+
+	pcd.stdin = strings.NewReader("\n\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "See also:\n  - Runbook: https://wiki.example.com/runbook\n  - Dashboard: https://grafana.example.com/d/service")
+}
+
+// Inputs whose values are supplied to ExecuteStepWithValues shouldn't be prompted for, while other
+// inputs should be.
+func TestProcedure_ExecuteStepWithValues(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart a service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findService")
+		step.Short("Find the service")
+		step.OutputString("HostName", "The host running the service")
+		step.OutputString("ServiceName", "The name of the service")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the service")
+		step.InputString("HostName", true)
+		step.InputString("ServiceName", true)
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{"", "nginx", ""}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.ExecuteStepWithValues("root.restart", map[string]string{"HostName": "db01"}))
+	assert.NotContains(stdout.String(), "Value for input 'HostName'")
+	assert.Contains(stdout.String(), "Value for input 'ServiceName'")
+	assert.Equal("db01", pcd.LastRunValues()["HostName"])
+	assert.Equal("nginx", pcd.LastRunValues()["ServiceName"])
+}
+
+// A step's ordered list of actions should be rendered as a numbered list after its long
+// description, both in Markdown and during execution.
+func TestProcedure_LongSteps(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Rotate the certificate")
+	pcd.AddStep(func(step *Step) {
+		step.Name("rotate")
+		step.Short("Rotate the certificate")
+		step.Long("Do this during the maintenance window.")
+		step.LongSteps(
+			"Generate a new key with @@make-key@@",
+			"Upload the certificate",
+			"  Restart the load balancer  ",
+		)
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("verify")
+		step.Short("Verify the certificate")
+		step.LongSteps("Open the site", "Check the expiry date")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "[Up](#rotate-the-certificate)\n\nDo this during the maintenance window.\n\n1. Generate a new key with `make-key`\n2. Upload the certificate\n3. Restart the load balancer\n")
+	assert.Contains(b.String(), "[Up](#rotate-the-certificate)\n\n1. Open the site\n2. Check the expiry date\n")
+	assert.Equal("Do this during the maintenance window.", pcd.rootStep.children[0].GetLong())
+
+	pcd.stdin = strings.NewReader("\n\n\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "Do this during the maintenance window.\n\n1. Generate a new key with `make-key`\n2. Upload the certificate\n3. Restart the load balancer\n")
+}
+
+// DeduplicateNames should rename steps with duplicate absolute names so that Check passes, without
+// disturbing the matching of inputs to outputs.
+func TestProcedure_DeduplicateNames(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	restart := func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the service")
+		step.InputString("HostName", true)
+		step.AddStep(func(step *Step) {
+			step.Name("wait")
+			step.Short("Wait for the service to come up")
+		})
+	}
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service a few times")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the host")
+		step.OutputString("HostName", "The host running the service")
+	})
+	pcd.AddStep(restart)
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart2")
+		step.Short("Restart the service again")
+	})
+	pcd.AddStep(restart)
+	pcd.AddStep(restart)
+
+	_, err := pcd.Check()
+	assert.NotNil(err)
+
+	assert.Equal([]string{
+		"Renamed step 'root.restart' to 'root.restart3'",
+		"Renamed step 'root.restart' to 'root.restart4'",
+	}, pcd.DeduplicateNames())
+	problems, err := pcd.Check()
+	assert.Nil(err)
+	assert.Equal([]string{}, problems)
+	assert.Equal([]string{}, pcd.DeduplicateNames())
+
+	names := make([]string, 0)
+	pcd.rootStep.Walk(func(step *Step) error {
+		names = append(names, step.AbsoluteName())
+		return nil
+	})
+	assert.Equal([]string{
+		"root",
+		"root.findHost",
+		"root.restart",
+		"root.restart.wait",
+		"root.restart2",
+		"root.restart3",
+		"root.restart3.wait",
+		"root.restart4",
+		"root.restart4.wait",
+	}, names)
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{"", "", "db01", "", "", "", "", "", "", ""}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.NotContains(stdout.String(), "Value for input 'HostName'")
+	assert.Equal("db01", pcd.LastRunValues()["HostName"])
+}
+
+// Input should be read from the answers source set with SetAnswers until it's exhausted, and then
+// from the input set with SetInput.
+func TestProcedure_SetAnswers(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Find the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findService")
+		step.Short("Find the service")
+		step.OutputString("HostName", "The host running the service")
+		step.OutputString("ServiceName", "The name of the service")
+	})
+
+	pcd.SetAnswers(strings.NewReader("\n\ndb01"))
+	pcd.SetInput(strings.NewReader("postgres\n"))
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "The host running the service (HostName): db01\n")
+	assert.Equal("db01", pcd.LastRunValues()["HostName"])
+	assert.Equal("postgres", pcd.LastRunValues()["ServiceName"])
+}
+
+// Secret values read from the answers source shouldn't be echoed.
+func TestProcedure_SetAnswers_Secret(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Log in")
+	pcd.AddStep(func(step *Step) {
+		step.Name("getPassword")
+		step.Short("Get the password")
+		step.OutputStringSecret("Password", "The password")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("logIn")
+		step.Short("Log in")
+		step.InputString("Password", true)
+	})
+
+	pcd.SetAnswers(strings.NewReader("\n\nhunter2\n"))
+	pcd.SetInput(strings.NewReader("\n"))
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "The password (Password): REDACTED\n")
+	assert.NotContains(stdout.String(), "hunter2")
+}
+
+// RenderOutline should print a nested ordered list of step titles, numbered to match the steps'
+// numeric paths.
+func TestProcedure_RenderOutline(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.Long("This part shouldn't appear in the outline.")
+		step.AddStep(func(step *Step) {
+			step.Name("remove")
+			step.Short("Remove the server from the load balancer")
+			step.AddStep(func(step *Step) {
+				step.Name("find")
+				step.Short("Find the server's @@pool@@")
+			})
+			step.AddStep(func(step *Step) {
+				step.Name("disable")
+				step.Short("Disable the server in the pool")
+			})
+		})
+		step.AddStep(func(step *Step) {
+			step.Name("wait")
+			step.Short("Wait for connections to close")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderOutline(&b))
+	assert.Equal(`# Restart the service
+
+0. Drain traffic
+    0. Remove the server from the load balancer
+        0. Find the server's `+"`pool`"+`
+        1. Disable the server in the pool
+    1. Wait for connections to close
+1. Restart the server
+`, b.String())
+}
+
+// RenderFiltered should leave out the sections of steps that aren't kept, while keeping their
+// ancestors and renumbering the remaining steps.
+func TestProcedure_RenderFiltered(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("announce")
+		step.Short("Announce the restart")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.AddStep(func(step *Step) {
+			step.Name("remove")
+			step.Short("Remove the server from the load balancer")
+		})
+		step.AddStep(func(step *Step) {
+			step.Name("wait")
+			step.Short("Wait for connections to close")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+	})
+
+	operator := map[string]bool{
+		"root.drain.wait": true,
+		"root.restart":    true,
+	}
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderFiltered(&b, func(step *Step) bool {
+		return operator[step.AbsoluteName()]
+	}))
+	assert.NotContains(b.String(), "Announce the restart")
+	assert.NotContains(b.String(), "Remove the server")
+	assert.Contains(b.String(), "## (0) Drain traffic\n")
+	assert.Contains(b.String(), "### (0.0) Wait for connections to close\n")
+	assert.Contains(b.String(), "## (1) Restart the server\n")
+	assert.Contains(b.String(), "- [Drain traffic](#0-drain-traffic)\n    - [Wait for connections to close](#00-wait-for-connections-to-close)\n- [Restart the server](#1-restart-the-server)")
+}
+
+// The errors returned for a missing step, a failed check, and quitting should be distinguishable
+// with errors.Is and errors.As.
+func TestProcedure_Errors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the service")
+	})
+
+	_, err := pcd.GetStepByName("root.nonexistent")
+	assert.True(errors.Is(err, ErrStepNotFound))
+	assert.EqualError(err, "No step with name 'root.nonexistent': step not found")
+	err = pcd.RenderStep(&bytes.Buffer{}, "root.nonexistent")
+	assert.True(errors.Is(err, ErrStepNotFound))
+	err = pcd.ExecuteStep("root.nonexistent")
+	assert.True(errors.Is(err, ErrStepNotFound))
+
+	pcd.SetMenuPrompt(true)
+	pcd.stdin = strings.NewReader("4\n")
+	pcd.stdout = &bytes.Buffer{}
+	err = pcd.Execute()
+	assert.True(errors.Is(err, ErrAborted))
+	assert.False(errors.Is(err, ErrCheckFailed))
+
+	pcd.AddStep(func(step *Step) {
+		step.Name("verify")
+		step.InputString("HostName", true)
+	})
+	problems, err := pcd.Check()
+	assert.True(errors.Is(err, ErrCheckFailed))
+	var checkErr *CheckError
+	if assert.True(errors.As(err, &checkErr)) {
+		assert.Equal(problems, checkErr.Problems)
+	}
+	for _, err := range []error{
+		pcd.Render(&bytes.Buffer{}),
+		pcd.ExecuteStep("root.restart"),
+	} {
+		checkErr = nil
+		if assert.True(errors.As(err, &checkErr)) {
+			assert.Contains(checkErr.Problems, "Step 'root.verify' has no Short value")
+		}
+	}
+}
+
+// With SetConfirmInputs, the user should be asked to confirm each value they enter, and prompted
+// again if it's wrong.
+func TestProcedure_SetConfirmInputs(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Log in to the database")
+	pcd.SetConfirmInputs(true)
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the database host")
+		step.OutputString("HostName", "The database host")
+		step.OutputStringSecret("Password", "The database password")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// findHost
+		"",
+		"db10",
+		"n",
+		"db01",
+		"y",
+		"hunter2",
+		"y",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "You entered 'db10'. Correct? [y/n]: The database host (HostName): ")
+	assert.Contains(stdout.String(), "You entered 'db01'. Correct? [y/n]: ")
+	assert.Contains(stdout.String(), "You entered a secret value. Correct? [y/n]: ")
+	assert.NotContains(stdout.String(), "hunter2")
+	assert.Equal("db01", pcd.LastRunValues()["HostName"])
+	assert.Equal(RedactedValue, pcd.LastRunReport().Values["Password"])
+}
+
+// RenderRunSheet should leave a blank for each output and a sign-off line for each step.
+func TestProcedure_RenderRunSheet(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Fail over the database")
+	pcd.AddStep(func(step *Step) {
+		step.Name("findHost")
+		step.Short("Find the database host")
+		step.Long("Look up the host in the inventory.")
+		step.OutputString("HostName", "The database host")
+		step.Output("int", "Port", "The database port")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("promote")
+		step.Short("Promote the replica")
+		step.InputString("HostName", true)
+		step.AddStep(func(step *Step) {
+			step.Name("verify")
+			step.Short("Verify the promotion")
+			step.OutputStringList("Replicas", "The remaining replicas")
+		})
+	})
+
+	var b bytes.Buffer
+	assert.Nil(pcd.RenderRunSheet(&b))
+	blank := strings.Repeat("_", 30)
+	signOff := fmt.Sprintf("  Done by: %s Time: %s", blank, blank)
+	assert.Equal(`Run sheet: Fail over the database
+
+Operator: `+blank+` Date: `+blank+`
+
+(0) Find the database host
+
+Look up the host in the inventory.
+
+  HostName (The database host): `+blank+`
+
+  Port (The database port): `+blank+`
+
+`+signOff+`
+
+(1) Promote the replica
+
+`+signOff+`
+
+(1.0) Verify the promotion
+
+  Replicas (The remaining replicas), one per line:
+    `+blank+`
+    `+blank+`
+    `+blank+`
+
+`+signOff+`
+`, b.String())
+}
+
+// With SetStatusWriter, a status line should be written to the status writer at each step
+// transition.
+func TestProcedure_SetStatusWriter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+		step.AddStep(func(step *Step) {
+			step.Name("wait")
+			step.Short("Wait for the server to come up")
+		})
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("verify")
+		step.Short("Verify the service")
+	})
+
+	var status bytes.Buffer
+	pcd.SetStatusWriter(&status)
+	pcd.stdin = strings.NewReader(strings.Join([]string{"", "", "skip", ""}, "\n") + "\n")
+	pcd.stdout = &bytes.Buffer{}
+	assert.Nil(pcd.Execute())
+
+	lines := strings.Split(strings.TrimSuffix(status.String(), "\n"), "\n")
+	for i, line := range lines {
+		assert.Regexp(`^[A-Z]+( \S+)? \d\d:\d\d:\d\d$`, line)
+		lines[i] = regexp.MustCompile(` \d\d:\d\d:\d\d$`).ReplaceAllString(line, "")
+	}
+	assert.Equal([]string{
+		"RUNNING root",
+		"RUNNING root.drain",
+		"RUNNING root.restart",
+		"SKIPPED root.restart",
+		"RUNNING root.verify",
+		"DONE",
+	}, lines)
+}
+
+// When the user aborts execution, the final status line should say so instead of reporting a
+// failure.
+func TestProcedure_SetStatusWriter_Aborted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Restart the service")
+	pcd.Prerequisite("You have access to the production cluster")
+	pcd.AddStep(func(step *Step) {
+		step.Name("restart")
+		step.Short("Restart the server")
+	})
+
+	var status bytes.Buffer
+	pcd.SetStatusWriter(&status)
+	pcd.stdin = strings.NewReader("n\n")
+	pcd.stdout = &bytes.Buffer{}
+	err := pcd.Execute()
+	assert.True(errors.Is(err, ErrAborted))
+	assert.Regexp(`^ABORTED \d\d:\d\d:\d\d\n$`, status.String())
+}
@@ -0,0 +1,500 @@
+package donothing
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// RenderStepHTML prints the given step from the procedure as an HTML fragment to f.
+//
+// Each step becomes a <section> element with a header, the step's long description split into
+// paragraphs, and lists of its inputs and outputs. Text between pairs of backtick standins ("@@")
+// is rendered as code, as described in SetStrictStandins.
+func (pcd *Procedure) RenderStepHTML(f io.Writer, stepName string) error {
+	td, err := pcd.renderData(stepName)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	writeHTMLStep(&b, td, pcd.strictStandins)
+	fmt.Fprintf(f, "%s", b.String())
+	return nil
+}
+
+// writeHTMLStep writes the HTML for td and its descendants to b.
+//
+// strict has the same meaning as for convertStandins.
+func writeHTMLStep(b *strings.Builder, td StepTemplateData, strict bool) {
+	level := td.headingLevel()
+	fmt.Fprintf(b, "<section id=\"%s\">\n", html.EscapeString(strings.TrimPrefix(td.Anchor(), "#")))
+	fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, htmlText(td.headingText(), strict), level)
+	if td.Parent != nil {
+		fmt.Fprintf(
+			b,
+			"<p><code>%s</code> • <a href=\"%s\">Up</a></p>\n",
+			html.EscapeString(td.StepName),
+			html.EscapeString(td.ParentAnchor()),
+		)
+	}
+	for _, para := range strings.Split(td.Body, "\n\n") {
+		if strings.TrimSpace(para) != "" {
+			fmt.Fprintf(b, "<p>%s</p>\n", htmlText(para, strict))
+		}
+	}
+	if len(td.LongSteps) > 0 {
+		b.WriteString("<ol>\n")
+		for _, item := range td.LongSteps {
+			fmt.Fprintf(b, "<li>%s</li>\n", htmlText(item, strict))
+		}
+		b.WriteString("</ol>\n")
+	}
+	if td.ExpectedOutcome != "" {
+		fmt.Fprintf(b, "<p><strong>Expected</strong>: %s</p>\n", htmlText(td.ExpectedOutcome, strict))
+	}
+	if td.RepeatFor != "" {
+		fmt.Fprintf(
+			b,
+			"<p><strong>Repeat</strong> this step for each item in <code>%s</code>.</p>\n",
+			html.EscapeString(td.RepeatFor),
+		)
+	}
+	if len(td.Links) > 0 {
+		b.WriteString("<p><strong>See also</strong>:</p>\n<ul>\n")
+		for _, link := range td.Links {
+			fmt.Fprintf(
+				b,
+				"<li><a href=\"%s\">%s</a></li>\n",
+				html.EscapeString(link.URL),
+				htmlText(link.Label, strict),
+			)
+		}
+		b.WriteString("</ul>\n")
+	}
+	if len(td.InputDefs) > 0 {
+		b.WriteString("<p><strong>Inputs</strong>:</p>\n<ul>\n")
+		for _, inputDef := range td.InputDefs {
+			fmt.Fprintf(b, "<li><code>%s</code></li>\n", html.EscapeString(inputDef.Name))
+		}
+		b.WriteString("</ul>\n")
+	}
+	if len(td.OutputDefs) > 0 {
+		b.WriteString("<p><strong>Outputs</strong>:</p>\n<ul>\n")
+		for _, outputDef := range td.OutputDefs {
+			fmt.Fprintf(
+				b,
+				"<li><code>%s</code> (%s): %s",
+				html.EscapeString(outputDef.Name),
+				html.EscapeString(outputDef.ValueType),
+				htmlText(outputDef.Short, strict),
+			)
+			if outputDef.Optional {
+				b.WriteString(" (optional)")
+			}
+			if outputDef.Example != "" {
+				fmt.Fprintf(b, " (e.g. <code>%s</code>)", html.EscapeString(outputDef.Example))
+			}
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ul>\n")
+	}
+	for _, c := range td.Children {
+		writeHTMLStep(b, c, strict)
+	}
+	b.WriteString("</section>\n")
+}
+
+// htmlText escapes s for inclusion in HTML, rendering text between pairs of backtick standins as
+// code.
+//
+// strict has the same meaning as for convertStandins. A standin with no partner is left alone,
+// since it can't be rendered as an element without leaving the element unclosed.
+func htmlText(s string, strict bool) string {
+	return convertStandins(html.EscapeString(s), strict, "<code>", "</code>", backtickStandin)
+}
+
+// RenderStepText prints the given step from the procedure as plain text to f.
+//
+// The text contains the same information as the Markdown documentation, but without any Markdown
+// syntax. Step bodies are wrapped as during Execute; see SetWrapWidth. Pairs of backtick standins
+// ("@@") are replaced with backticks, as described in SetStrictStandins.
+func (pcd *Procedure) RenderStepText(f io.Writer, stepName string) error {
+	td, err := pcd.renderData(stepName)
+	if err != nil {
+		return err
+	}
+
+	blocks := make([]string, 0)
+	var addStep func(StepTemplateData)
+	addStep = func(td StepTemplateData) {
+		blocks = append(blocks, td.headingText())
+		if td.Body != "" {
+			blocks = append(blocks, wrapText(td.Body, pcd.wrapWidth))
+		}
+		if len(td.LongSteps) > 0 {
+			blocks = append(blocks, td.NumberedSteps())
+		}
+		if td.ExpectedOutcome != "" {
+			blocks = append(blocks, fmt.Sprintf("Expected: %s", td.ExpectedOutcome))
+		}
+		if td.RepeatFor != "" {
+			blocks = append(blocks, fmt.Sprintf("Repeat this step for each item in @@%s@@.", td.RepeatFor))
+		}
+		if len(td.Links) > 0 {
+			lines := []string{"See also:"}
+			for _, link := range td.Links {
+				lines = append(lines, fmt.Sprintf("  - %s: %s", link.Label, link.URL))
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+		if len(td.InputDefs) > 0 {
+			lines := []string{"Inputs:"}
+			for _, inputDef := range td.InputDefs {
+				lines = append(lines, fmt.Sprintf("  - @@%s@@", inputDef.Name))
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+		if len(td.OutputDefs) > 0 {
+			lines := []string{"Outputs:"}
+			for _, outputDef := range td.OutputDefs {
+				line := fmt.Sprintf("  - @@%s@@ (%s): %s", outputDef.Name, outputDef.ValueType, outputDef.Short)
+				if outputDef.Optional {
+					line = fmt.Sprintf("%s (optional)", line)
+				}
+				if outputDef.Example != "" {
+					line = fmt.Sprintf("%s (e.g. @@%s@@)", line, outputDef.Example)
+				}
+				lines = append(lines, line)
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+		for _, c := range td.Children {
+			addStep(c)
+		}
+	}
+	addStep(td)
+
+	s := strings.Join(blocks, "\n\n") + "\n"
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(s))
+	return nil
+}
+
+// RenderRollback prints a standalone rollback procedure, built from the rollback instructions of
+// the procedure's steps, as Markdown to f.
+//
+// The rollback procedure has a section for each step that has rollback instructions (see
+// Step.Rollback), in the reverse of the order in which the steps are executed, so that the most
+// recent change is undone first. Each section is titled with the section number and short
+// description of the step it undoes. Pairs of backtick standins ("@@") are replaced with
+// backticks, as described in SetStrictStandins.
+func (pcd *Procedure) RenderRollback(f io.Writer) error {
+	td, err := pcd.renderData(pcd.rootStep.AbsoluteName())
+	if err != nil {
+		return err
+	}
+
+	// Every section is one level below the title.
+	level := td.headingLevel() + 1
+	if level > 6 {
+		level = 6
+	}
+	sections := make([]string, 0)
+	var addStep func(StepTemplateData)
+	addStep = func(td StepTemplateData) {
+		if td.Rollback != "" {
+			sections = append(sections, fmt.Sprintf(
+				"%s Roll back %s\n\n@@%s@@\n\n%s",
+				strings.Repeat("#", level),
+				td.headingText(),
+				td.StepName,
+				td.Rollback,
+			))
+		}
+		for _, c := range td.Children {
+			addStep(c)
+		}
+	}
+	addStep(td)
+
+	blocks := []string{fmt.Sprintf("%s Rollback: %s", strings.Repeat("#", td.headingLevel()), td.Title)}
+	if len(sections) == 0 {
+		blocks = append(blocks, "No steps have rollback instructions.")
+	}
+	for i := len(sections) - 1; i >= 0; i-- {
+		blocks = append(blocks, sections[i])
+	}
+
+	s := strings.Join(blocks, "\n\n") + "\n"
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(s))
+	return nil
+}
+
+// RenderOutline prints an outline of the procedure, consisting only of its steps' titles, as
+// Markdown to f.
+//
+// The outline is a nested ordered list, with each step's substeps in a list under it, like so:
+//
+//     # Restart the service
+//
+//     0. Drain traffic
+//         0. Remove the server from the load balancer
+//         1. Wait for connections to close
+//     1. Restart the server
+//
+// Each step is numbered with its position among its siblings, so the numbers along the way to a
+// step make up its numeric path, as in the section headers of the full Markdown documentation.
+// Pairs of backtick standins ("@@") are replaced with backticks, as described in
+// SetStrictStandins.
+func (pcd *Procedure) RenderOutline(f io.Writer) error {
+	td, err := pcd.renderData(pcd.rootStep.AbsoluteName())
+	if err != nil {
+		return err
+	}
+
+	items := make([]string, 0)
+	var addStep func(StepTemplateData, int)
+	addStep = func(td StepTemplateData, indent int) {
+		for i, c := range td.Children {
+			items = append(items, fmt.Sprintf("%s%d. %s", strings.Repeat("    ", indent), i, c.Title))
+			addStep(c, indent+1)
+		}
+	}
+	addStep(td, 0)
+
+	blocks := []string{fmt.Sprintf("%s %s", strings.Repeat("#", td.headingLevel()), td.Title)}
+	if len(items) > 0 {
+		blocks = append(blocks, strings.Join(items, "\n"))
+	}
+	s := strings.Join(blocks, "\n\n") + "\n"
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(s))
+	return nil
+}
+
+// runSheetBlank is the blank left on a run sheet for the operator to fill in a value by hand.
+var runSheetBlank = strings.Repeat("_", 30)
+
+// RenderRunSheet prints a blank run sheet for the procedure to f, as plain text.
+//
+// A run sheet is meant to be printed out, so that the procedure can be executed on paper. After a
+// header with blanks for the operator's name and the date and the procedure's long description,
+// each step is listed with its long description and expected outcome, followed by a blank for the
+// value of each of its outputs and a sign-off line for the operator to initial once the step is
+// done, like so:
+//
+//     (0) Find the database host
+//
+//     Look up the host in the inventory.
+//
+//       HostName (The database host): ______________________________
+//
+//       Done by: ______________________________ Time: ______________________________
+//
+// A "stringlist" output gets a blank for each of three items. Pairs of backtick standins ("@@") are
+// replaced with backticks, as described in SetStrictStandins.
+func (pcd *Procedure) RenderRunSheet(f io.Writer) error {
+	td, err := pcd.renderData(pcd.rootStep.AbsoluteName())
+	if err != nil {
+		return err
+	}
+
+	blocks := []string{
+		fmt.Sprintf("Run sheet: %s", td.Title),
+		fmt.Sprintf("Operator: %s Date: %s", runSheetBlank, runSheetBlank),
+	}
+	var addStep func(StepTemplateData)
+	addStep = func(td StepTemplateData) {
+		// The root step's title is already in the header.
+		if td.Depth > 0 {
+			blocks = append(blocks, td.headingText())
+		}
+		if td.Body != "" {
+			blocks = append(blocks, td.Body)
+		}
+		if len(td.LongSteps) > 0 {
+			blocks = append(blocks, td.NumberedSteps())
+		}
+		if td.ExpectedOutcome != "" {
+			blocks = append(blocks, fmt.Sprintf("Expected: %s", td.ExpectedOutcome))
+		}
+		if td.RepeatFor != "" {
+			blocks = append(blocks, fmt.Sprintf("Repeat this step for each item in @@%s@@.", td.RepeatFor))
+		}
+		for _, outputDef := range td.OutputDefs {
+			lines := []string{fmt.Sprintf("  %s (%s): %s", outputDef.Name, outputDef.Short, runSheetBlank)}
+			if outputDef.ValueType == "stringlist" {
+				lines = []string{fmt.Sprintf("  %s (%s), one per line:", outputDef.Name, outputDef.Short)}
+				for i := 0; i < 3; i++ {
+					lines = append(lines, fmt.Sprintf("    %s", runSheetBlank))
+				}
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+		if td.Depth > 0 {
+			blocks = append(blocks, fmt.Sprintf("  Done by: %s Time: %s", runSheetBlank, runSheetBlank))
+		}
+		for _, c := range td.Children {
+			addStep(c)
+		}
+	}
+	addStep(td)
+
+	s := strings.Join(blocks, "\n\n") + "\n"
+	fmt.Fprintf(f, "%s", pcd.replaceStandins(s))
+	return nil
+}
+
+// jsonStep is the JSON representation of a step, as printed by RenderStepJSON.
+type jsonStep struct {
+	Name            string       `json:"name"`
+	Short           string       `json:"short"`
+	Long            string       `json:"long,omitempty"`
+	LongSteps       []string     `json:"longSteps,omitempty"`
+	ExpectedOutcome string       `json:"expectedOutcome,omitempty"`
+	Rollback        string       `json:"rollback,omitempty"`
+	RepeatFor       string       `json:"repeatFor,omitempty"`
+	References      []string     `json:"references,omitempty"`
+	Links           []jsonLink   `json:"links,omitempty"`
+	Inputs          []jsonInput  `json:"inputs,omitempty"`
+	Outputs         []jsonOutput `json:"outputs,omitempty"`
+	Children        []jsonStep   `json:"children,omitempty"`
+}
+
+type jsonLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+type jsonInput struct {
+	Name      string `json:"name"`
+	ValueType string `json:"valueType"`
+	Required  bool   `json:"required"`
+}
+
+type jsonOutput struct {
+	Name      string `json:"name"`
+	ValueType string `json:"valueType"`
+	Short     string `json:"short"`
+	Secret    bool   `json:"secret,omitempty"`
+	Optional  bool   `json:"optional,omitempty"`
+	Example   string `json:"example,omitempty"`
+}
+
+// newJSONStep returns the JSON representation of td and its descendants.
+func newJSONStep(td StepTemplateData) jsonStep {
+	js := jsonStep{
+		Name:            td.StepName,
+		Short:           td.Title,
+		Long:            td.Body,
+		LongSteps:       td.LongSteps,
+		ExpectedOutcome: td.ExpectedOutcome,
+		Rollback:        td.Rollback,
+		RepeatFor:       td.RepeatFor,
+	}
+	if td.LiteralBody {
+		js.Long = unescapeStandins(td.Body)
+	}
+	for _, ref := range td.References {
+		js.References = append(js.References, ref.Name)
+	}
+	for _, link := range td.Links {
+		js.Links = append(js.Links, jsonLink{Label: link.Label, URL: link.URL})
+	}
+	for _, inputDef := range td.InputDefs {
+		js.Inputs = append(js.Inputs, jsonInput{
+			Name:      inputDef.Name,
+			ValueType: inputDef.ValueType,
+			Required:  inputDef.Required,
+		})
+	}
+	for _, outputDef := range td.OutputDefs {
+		js.Outputs = append(js.Outputs, jsonOutput{
+			Name:      outputDef.Name,
+			ValueType: outputDef.ValueType,
+			Short:     outputDef.Short,
+			Secret:    outputDef.Secret,
+			Optional:  outputDef.Optional,
+			Example:   outputDef.Example,
+		})
+	}
+	for _, c := range td.Children {
+		js.Children = append(js.Children, newJSONStep(c))
+	}
+	return js
+}
+
+// RenderStepJSON prints the given step from the procedure, and its descendants, as JSON to f.
+//
+// The JSON is an object describing the step, with the step's substeps nested under "children".
+// Descriptions are printed as given, without replacing "@@" sequences.
+func (pcd *Procedure) RenderStepJSON(f io.Writer, stepName string) error {
+	td, err := pcd.renderData(stepName)
+	if err != nil {
+		return err
+	}
+
+	j, err := json.MarshalIndent(newJSONStep(td), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "%s\n", j)
+	return nil
+}
+
+// RenderStepMermaid prints the given step from the procedure as a Mermaid flowchart to f.
+//
+// Each step is a node in the flowchart. A solid arrow leads from each step to each of its
+// substeps, and a dotted arrow, labeled with the output's name, leads from the step that produces
+// an output to each step that takes it as an input.
+func (pcd *Procedure) RenderStepMermaid(f io.Writer, stepName string) error {
+	td, err := pcd.renderData(stepName)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{"flowchart TD"}
+	edges := make([]string, 0)
+	producers := make(map[string]string)
+	n := 0
+	var addStep func(StepTemplateData, string)
+	addStep = func(td StepTemplateData, parentID string) {
+		id := fmt.Sprintf("step%d", n)
+		n++
+		lines = append(lines, fmt.Sprintf("    %s[\"%s\"]", id, mermaidText(pcd.replaceStandins(td.headingText()))))
+		if parentID != "" {
+			edges = append(edges, fmt.Sprintf("    %s --> %s", parentID, id))
+		}
+		for _, inputDef := range td.InputDefs {
+			if producerID, ok := producers[inputDef.Name]; ok {
+				edges = append(edges, fmt.Sprintf(
+					"    %s -.->|%s| %s",
+					producerID,
+					mermaidText(inputDef.Name),
+					id,
+				))
+			}
+		}
+		for _, outputDef := range td.OutputDefs {
+			producers[outputDef.Name] = id
+		}
+		for _, c := range td.Children {
+			addStep(c, id)
+		}
+	}
+	addStep(td, "")
+
+	lines = append(lines, edges...)
+	fmt.Fprintf(f, "%s\n", strings.Join(lines, "\n"))
+	return nil
+}
+
+// mermaidText escapes s for use as a Mermaid node or edge label.
+func mermaidText(s string) string {
+	r := strings.NewReplacer(
+		`"`, "#quot;",
+		"|", "#124;",
+	)
+	return r.Replace(s)
+}
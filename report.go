@@ -0,0 +1,116 @@
+package donothing
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RedactedValue is the placeholder that appears in place of the value of a secret output, both in
+// a RunReport and in what's shown to the user during execution.
+const RedactedValue = "REDACTED"
+
+// A RunReport records what happened during an execution of a procedure.
+//
+// The report for the most recent execution can be obtained with Procedure.LastRunReport.
+type RunReport struct {
+	// When execution started and ended.
+	Start time.Time
+	End   time.Time
+
+	// The steps that were visited during execution, in the order they were visited.
+	Steps []StepReport
+
+	// The values collected during execution, keyed by name. The values of secret outputs are
+	// replaced with RedactedValue.
+	Values map[string]string
+
+	// The error that caused execution to end early, if any.
+	Error string
+
+	// The environment captured at the start of execution, if Procedure.CaptureEnv was called.
+	//
+	// Env holds the captured environment variables, keyed by name. Variables that are unset are
+	// omitted, and the values of secret variables are replaced with RedactedValue.
+	Hostname string
+	User     string
+	Env      map[string]string
+}
+
+// A StepReport records what happened to a single step during an execution of a procedure.
+type StepReport struct {
+	// The step's absolute name.
+	Name string
+	// If the step repeats for each item in a list, the item for this iteration.
+	Item string
+
+	// When the step was shown to the user, and how long it was until they moved on.
+	Start    time.Time
+	Duration time.Duration
+
+	// Whether the step was skipped, either directly or on the way to another step.
+	Skipped bool
+	// Any notes the user entered at the step's prompt.
+	Notes []string
+	// Whether the user reported that the step's outcome didn't match its expected outcome.
+	OutcomeMismatch bool
+	// The error that occurred while executing the step, if any.
+	Error string
+}
+
+// MarshalJSON returns the JSON encoding of the report.
+//
+// Times are encoded in RFC 3339 format and durations are encoded as strings like "1m2.5s".
+func (rpt RunReport) MarshalJSON() ([]byte, error) {
+	type jsonStep struct {
+		Name     string   `json:"name"`
+		Item     string   `json:"item,omitempty"`
+		Start    string   `json:"start"`
+		Duration string   `json:"duration"`
+		Skipped  bool     `json:"skipped"`
+		Notes    []string `json:"notes,omitempty"`
+		Mismatch bool     `json:"outcomeMismatch,omitempty"`
+		Error    string   `json:"error,omitempty"`
+	}
+	type jsonReport struct {
+		Start    string            `json:"start"`
+		End      string            `json:"end"`
+		Duration string            `json:"duration"`
+		Steps    []jsonStep        `json:"steps"`
+		Values   map[string]string `json:"values"`
+		Error    string            `json:"error,omitempty"`
+		Hostname string            `json:"hostname,omitempty"`
+		User     string            `json:"user,omitempty"`
+		Env      map[string]string `json:"env,omitempty"`
+	}
+
+	steps := make([]jsonStep, len(rpt.Steps))
+	for i, s := range rpt.Steps {
+		steps[i] = jsonStep{
+			Name:     s.Name,
+			Item:     s.Item,
+			Start:    s.Start.Format(time.RFC3339),
+			Duration: s.Duration.String(),
+			Skipped:  s.Skipped,
+			Notes:    s.Notes,
+			Mismatch: s.OutcomeMismatch,
+			Error:    s.Error,
+		}
+	}
+
+	values := rpt.Values
+	if values == nil {
+		values = make(map[string]string)
+	}
+
+	return json.Marshal(jsonReport{
+		Start:    rpt.Start.Format(time.RFC3339),
+		End:      rpt.End.Format(time.RFC3339),
+		Duration: rpt.End.Sub(rpt.Start).String(),
+		Steps:    steps,
+		Values:   values,
+		Error:    rpt.Error,
+		Hostname: rpt.Hostname,
+		User:     rpt.User,
+		Env:      rpt.Env,
+	})
+}
@@ -0,0 +1,138 @@
+package donothing
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RunReport.MarshalJSON should serialize the report of a completed run, redacting secrets.
+func TestRunReport_MarshalJSON(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Rotate the password")
+	pcd.AddStep(func(step *Step) {
+		step.Name("getPassword")
+		step.Short("Generate a new password")
+		step.OutputStringSecret("Password", "The new password")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("getHost")
+		step.Short("Pick a host")
+		step.InputString("Password", true)
+		step.OutputString("Host", "The host")
+	})
+	pcd.AddStep(func(step *Step) {
+		step.Name("optional")
+		step.Short("Do something optional")
+	})
+
+	pcd.stdin = strings.NewReader(strings.Join([]string{
+		// root
+		"",
+		// root.getPassword
+		"note generated with pwgen",
+		"",
+		"hunter2",
+		// root.getHost
+		"",
+		"db1",
+		// root.optional
+		"skip",
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	rpt := pcd.LastRunReport()
+	assert.NotNil(rpt)
+
+	b, err := json.Marshal(rpt)
+	assert.Nil(err)
+	assert.NotContains(string(b), "hunter2")
+	assert.NotContains(stdout.String(), "hunter2")
+	assert.Contains(stdout.String(), "  - Password: "+RedactedValue+"\n")
+
+	// The report should serialize the same way when it's not behind a pointer
+	byValue, err := json.Marshal(*rpt)
+	assert.Nil(err)
+	assert.Equal(string(b), string(byValue))
+	inStruct, err := json.Marshal(struct{ Report RunReport }{*rpt})
+	assert.Nil(err)
+	assert.Equal(`{"Report":`+string(b)+`}`, string(inStruct))
+
+	var decoded struct {
+		Start    string
+		End      string
+		Duration string
+		Steps    []struct {
+			Name     string
+			Start    string
+			Duration string
+			Skipped  bool
+			Notes    []string
+		}
+		Values map[string]string
+	}
+	assert.Nil(json.Unmarshal(b, &decoded))
+
+	assert.NotEmpty(decoded.Start)
+	assert.NotEmpty(decoded.End)
+	assert.NotEmpty(decoded.Duration)
+	assert.Equal(map[string]string{"Password": RedactedValue, "Host": "db1"}, decoded.Values)
+
+	assert.Equal(4, len(decoded.Steps))
+	names := make([]string, 0)
+	for _, s := range decoded.Steps {
+		names = append(names, s.Name)
+		assert.NotEmpty(s.Start)
+		assert.NotEmpty(s.Duration)
+	}
+	assert.Equal([]string{"root", "root.getPassword", "root.getHost", "root.optional"}, names)
+	assert.Equal([]string{"generated with pwgen"}, decoded.Steps[1].Notes)
+	assert.False(decoded.Steps[2].Skipped)
+	assert.True(decoded.Steps[3].Skipped)
+}
+
+// CaptureEnv should record the environment in the run report, with secret variables redacted.
+//
+// This test sets environment variables, so it isn't run in parallel.
+func TestProcedure_CaptureEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("DONOTHING_TEST_REGION", "us-east-1")
+	defer os.Unsetenv("DONOTHING_TEST_REGION")
+	os.Setenv("DONOTHING_TEST_API_TOKEN", "abc123")
+	defer os.Unsetenv("DONOTHING_TEST_API_TOKEN")
+
+	pcd := NewProcedure()
+	pcd.Short("Root step")
+	pcd.CaptureEnv("DONOTHING_TEST_REGION", "DONOTHING_TEST_API_TOKEN", "DONOTHING_TEST_UNSET")
+
+	pcd.stdin = strings.NewReader("\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+
+	assert.Nil(pcd.Execute())
+	rpt := pcd.LastRunReport()
+	assert.Equal(
+		map[string]string{
+			"DONOTHING_TEST_REGION":    "us-east-1",
+			"DONOTHING_TEST_API_TOKEN": RedactedValue,
+		},
+		rpt.Env,
+	)
+	hostname, _ := os.Hostname()
+	assert.Equal(hostname, rpt.Hostname)
+
+	b, err := json.Marshal(rpt)
+	assert.Nil(err)
+	assert.Contains(string(b), `"env":{"DONOTHING_TEST_API_TOKEN":"REDACTED","DONOTHING_TEST_REGION":"us-east-1"}`)
+	assert.NotContains(string(b), "abc123")
+}
@@ -0,0 +1,104 @@
+package donothing
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// backtickStandin is the sequence that stands in for a backtick in step descriptions and templates,
+// which are written as Go raw strings and so can't contain backticks themselves.
+const backtickStandin = "@@"
+
+// convertStandins replaces the backtick standins in s with the given code span delimiters.
+//
+// Standins are paired up from left to right, and each pair becomes open and close respectively. A
+// standin preceded by a backslash is an escaped literal: the backslash is removed and the standin
+// is left alone. A standin with no partner becomes lone.
+//
+// If strict is true, only pairs of standins that look like code spans are converted: the opening
+// standin must be followed by a non-whitespace character, the closing standin must be preceded by
+// one, and both must be on the same line. Any other standins, including one with no partner, are
+// left alone.
+func convertStandins(s string, strict bool, open, close, lone string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		if strings.HasPrefix(s, `\`+backtickStandin) {
+			b.WriteString(backtickStandin)
+			s = s[len(backtickStandin)+1:]
+			continue
+		}
+		if strings.HasPrefix(s, backtickStandin) {
+			rest := s[len(backtickStandin):]
+			if end := closingStandin(rest, strict); end != -1 {
+				b.WriteString(open)
+				b.WriteString(strings.Replace(rest[:end], `\`+backtickStandin, backtickStandin, -1))
+				b.WriteString(close)
+				s = rest[end+len(backtickStandin):]
+				continue
+			}
+			if strict {
+				b.WriteString(backtickStandin)
+			} else {
+				b.WriteString(lone)
+			}
+			s = rest
+			continue
+		}
+		b.WriteByte(s[0])
+		s = s[1:]
+	}
+	return b.String()
+}
+
+// closingStandin returns the index in s of the standin that closes a code span opened just before
+// s, or -1 if there is none.
+//
+// strict has the same meaning as for convertStandins.
+func closingStandin(s string, strict bool) int {
+	if strict {
+		first, _ := utf8.DecodeRuneInString(s)
+		if s == "" || unicode.IsSpace(first) {
+			return -1
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		if strict && s[i] == '\n' {
+			return -1
+		}
+		if strings.HasPrefix(s[i:], `\`+backtickStandin) {
+			i += len(backtickStandin)
+			continue
+		}
+		if strings.HasPrefix(s[i:], backtickStandin) {
+			if strict {
+				last, _ := utf8.DecodeLastRuneInString(s[:i])
+				if i == 0 || unicode.IsSpace(last) {
+					return -1
+				}
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// replaceStandins replaces the backtick standins in s with backticks, according to the
+// procedure's SetStrictStandins setting.
+//
+// Outside of strict mode, a standin with no partner still becomes a backtick.
+func (pcd *Procedure) replaceStandins(s string) string {
+	return convertStandins(s, pcd.strictStandins, "`", "`", "`")
+}
+
+// escapeStandins escapes every backtick standin in s with a backslash, so that convertStandins
+// leaves s as is.
+func escapeStandins(s string) string {
+	return strings.Replace(s, backtickStandin, `\`+backtickStandin, -1)
+}
+
+// unescapeStandins reverses escapeStandins.
+func unescapeStandins(s string) string {
+	return strings.Replace(s, `\`+backtickStandin, backtickStandin, -1)
+}
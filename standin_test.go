@@ -0,0 +1,89 @@
+package donothing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// convertStandins should pair up backtick standins and convert them according to the mode.
+func TestConvertStandins(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		In     string
+		Strict bool
+		Out    string
+	}
+
+	testCases := []testCase{
+		// Code spans are converted in both modes
+		testCase{
+			In:     "Run @@make test@@ now",
+			Strict: false,
+			Out:    "Run `make test` now",
+		},
+		testCase{
+			In:     "Run @@make test@@ now",
+			Strict: true,
+			Out:    "Run `make test` now",
+		},
+		// An escaped standin is kept literal, and its backslash removed
+		testCase{
+			In:     `Log in as admin\@@host with @@ssh@@`,
+			Strict: false,
+			Out:    "Log in as admin@@host with `ssh`",
+		},
+		testCase{
+			In:     `Log in as admin\@@host with @@ssh@@`,
+			Strict: true,
+			Out:    "Log in as admin@@host with `ssh`",
+		},
+		// Without strict mode, an unpaired standin still becomes a backtick
+		testCase{
+			In:     "Run @@make@@ and then @@",
+			Strict: false,
+			Out:    "Run `make` and then `",
+		},
+		// In strict mode, an unpaired standin is kept literal
+		testCase{
+			In:     "Run @@make@@ and then @@",
+			Strict: true,
+			Out:    "Run `make` and then @@",
+		},
+		// Without strict mode, any pair is converted
+		testCase{
+			In:     "Between @@ and @@ here",
+			Strict: false,
+			Out:    "Between ` and ` here",
+		},
+		// In strict mode, a pair that doesn't look like a code span is kept literal
+		testCase{
+			In:     "Between @@ and @@ here",
+			Strict: true,
+			Out:    "Between @@ and @@ here",
+		},
+		testCase{
+			In:     "Across @@lines\nof text@@",
+			Strict: true,
+			Out:    "Across @@lines\nof text@@",
+		},
+		testCase{
+			In:     "Contact user@@example.com or run @@whoami@@",
+			Strict: true,
+			Out:    "Contact user@@example.com or run `whoami`",
+		},
+		// An escaped standin inside a code span is kept literal
+		testCase{
+			In:     `Type @@a\@@b@@`,
+			Strict: true,
+			Out:    "Type `a@@b`",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert.Equal(tc.Out, convertStandins(tc.In, tc.Strict, "`", "`", "`"))
+	}
+}
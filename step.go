@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 )
 
 // Special error returned by Step.Walk callbacks when they want to recurse no further into a step's
@@ -24,13 +25,43 @@ type Step struct {
 	name string
 	// The Step's short description, as set by Short()
 	short string
-	// The Step's long description, as set by Long()
+	// The Step's long description, as set by Long() or LongLiteral()
 	long string
+	// Whether the long description was set by LongLiteral(), and so must be rendered literally
+	literal bool
+	// The ordered list of actions the Step consists of, as set by LongSteps()
+	longSteps []string
+	// The Step's expected outcome, as set by ExpectedOutcome()
+	expectedOutcome string
 
 	// The Step's inputs and outputs, if any
 	inputs  []InputDef
 	outputs []OutputDef
 
+	// Instructions for undoing the Step, as set by Rollback()
+	rollback string
+	// The name of the list input over which the Step repeats, as set by RepeatFor()
+	repeatFor string
+	// The names of the outputs referenced by the Step, as set by ReferenceOutput()
+	references []string
+	// Links to external documentation about the Step, as set by Link()
+	links []ExternalLink
+	// The names of inputs in the order the user should be prompted for them, as set by
+	// PromptOrder()
+	promptOrder []string
+	// The question that decides which of the Step's children to execute, as set by Branch(). nil
+	// if the Step doesn't branch.
+	branch *Branch
+
+	// The Step's automated implementation, as set by Automate(). nil if the Step is manual.
+	fn func(*ExecContext) error
+	// How long the Step's automated implementation may run, as set by Timeout(). 0 means no limit.
+	timeout time.Duration
+	// Callbacks run around the user's interaction with the Step, as set by BeforePrompt() and
+	// AfterProceed(). nil if not set.
+	beforePrompt func(*ExecContext)
+	afterProceed func(*ExecContext)
+
 	// The Step of which this Step is a child. nil if this is the root step.
 	parent *Step
 	// The Step's substeps, if any
@@ -49,15 +80,25 @@ func (step *Step) Name(s string) {
 	step.name = s
 }
 
+// GetName returns the step's name, as set by Name().
+//
+// Unlike AbsoluteName, the returned name doesn't include the names of the step's ancestors.
+func (step *Step) GetName() string {
+	return step.name
+}
+
 // AbsoluteName returns the step's unique name.
 func (step *Step) AbsoluteName() string {
-	if step.parent == nil {
-		return step.name
+	// Build the name iteratively rather than recursively, so that very deep trees don't use up
+	// the stack.
+	names := make([]string, 0)
+	for s := step; s != nil; s = s.parent {
+		names = append(names, s.name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
 	}
-	return strings.Join([]string{
-		step.parent.AbsoluteName(),
-		step.name,
-	}, ".")
+	return strings.Join(names, ".")
 }
 
 // Pos returns the step's position in the tree.
@@ -99,35 +140,36 @@ func (step *Step) AbsoluteName() string {
 //     // Returns []int{1,2,0}
 //     pcd.GetStepByName("root.grandparent.parent.myStep").Pos()
 func (step *Step) Pos() []int {
-	if step.parent == nil {
-		return []int{}
-	}
-
-	var leafIndex int
-	leafIndex = -1
-	for i, child := range step.parent.children {
-		if child.AbsoluteName() == step.AbsoluteName() {
-			// it me!
-			leafIndex = i
-			break
+	pos := make([]int, 0)
+	for s := step; s.parent != nil; s = s.parent {
+		leafIndex := -1
+		for i, child := range s.parent.children {
+			if child == s {
+				// it me!
+				leafIndex = i
+				break
+			}
 		}
+		if leafIndex == -1 {
+			panic(fmt.Sprintf("step '%s' not found among parent's children", s.AbsoluteName()))
+		}
+		pos = append(pos, leafIndex)
 	}
-	if leafIndex == -1 {
-		panic(fmt.Sprintf("step '%s' not found among parent's children", step.AbsoluteName()))
+	for i, j := 0, len(pos)-1; i < j; i, j = i+1, j-1 {
+		pos[i], pos[j] = pos[j], pos[i]
 	}
-
-	parentPos := step.parent.Pos()
-	return append(parentPos, leafIndex)
+	return pos
 }
 
 // Depth returns the step's depth in the tree.
 //
 // The root node's depth is 0, the root node's children are at depth 1, and so on.
 func (step *Step) Depth() int {
-	if step.parent == nil {
-		return 0
+	depth := 0
+	for s := step.parent; s != nil; s = s.parent {
+		depth++
 	}
-	return step.parent.Depth() + 1
+	return depth
 }
 
 // Short gives the step a short description.
@@ -168,10 +210,16 @@ func (step *Step) GetShort() string {
 //
 //     "A long description of my step.\n\nBlah blah blah.\n\n    Indented line."
 //
-// Before a step is rendered, any occurrences of the "backtick standin sequence" in the long
-// description will be replaced with backtick characters. By default, the backtick standin sequence
-// is "@@". This sequence can be reassigned using Procedure.BacktickStandin().
+// Before a step is rendered, pairs of the "backtick standin sequence", "@@", in the long
+// description will be replaced with backtick characters. To keep a standin literal, escape it with
+// a backslash, as in "\@@". See Procedure.SetStrictStandins for more control over replacement.
 func (step *Step) Long(s string) {
+	step.literal = false
+	step.long = step.trimDescription(s)
+}
+
+// trimDescription massages a multi-line description as described in Long.
+func (step *Step) trimDescription(s string) string {
 	// Trim leading all-whitespace lines
 	r := regexp.MustCompile(`\A\s*\n`)
 	s = r.ReplaceAllString(s, "")
@@ -181,8 +229,61 @@ func (step *Step) Long(s string) {
 	s = r.ReplaceAllString(s, "")
 
 	// Remove any common indentation of the remaining lines
-	s = step.trimCommonIndent(s)
-	step.long = s
+	return step.trimCommonIndent(s)
+}
+
+// LongLiteral gives the step a long description that is rendered literally.
+//
+// LongLiteral is like Long, except that the description appears exactly as written: in the
+// rendered Markdown and AsciiDoc documents, its markup metacharacters are escaped with
+// backslashes, and in every other output, it's shown as is. Use it when the description isn't
+// under your control, for example when it's derived from user input.
+//
+// The backtick standin sequence isn't replaced with backticks, and variables (see
+// Procedure.SetVars) aren't substituted into the description.
+func (step *Step) LongLiteral(s string) {
+	step.Long(s)
+	step.literal = true
+}
+
+// IsLongLiteral returns whether the step's long description was set by LongLiteral().
+func (step *Step) IsLongLiteral() bool {
+	return step.literal
+}
+
+// LongSteps gives the step an ordered list of actions to perform.
+//
+// The list is rendered after the step's long description, if it has one, as a numbered Markdown
+// list starting at 1. When the procedure is executed, it's shown to the user in the same way. Use
+// it for steps whose instructions are naturally a short sequence of actions, rather than numbering
+// them by hand in the long description. Calling LongSteps again replaces the list.
+func (step *Step) LongSteps(items ...string) {
+	step.longSteps = make([]string, len(items))
+	for i, item := range items {
+		step.longSteps[i] = strings.TrimSpace(item)
+	}
+}
+
+// GetLongSteps returns the step's ordered list of actions, as set by LongSteps().
+func (step *Step) GetLongSteps() []string {
+	return step.longSteps
+}
+
+// markdownMetachars contains the characters escaped by escapeMarkdown.
+//
+// "@" is included so that the backtick standin sequence never appears in the escaped string.
+const markdownMetachars = "\\`*_{}[]()#+-.!|<>~@"
+
+// escapeMarkdown returns s with a backslash inserted before each markdown metacharacter.
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownMetachars, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // trimCommonIndent removes the longest common leading whitespace string from lines in s.
@@ -237,6 +338,109 @@ func (step *Step) GetLong() string {
 	return step.long
 }
 
+// ExpectedOutcome describes the result the user should see after performing the step.
+//
+// The expected outcome is rendered as a note in the step's section of the Markdown documentation.
+// When the procedure is executed, the user is shown the expected outcome and asked to confirm that
+// the actual outcome matched it. Any mismatch is recorded in the run report.
+func (step *Step) ExpectedOutcome(s string) {
+	step.expectedOutcome = s
+}
+
+// GetExpectedOutcome returns the step's expected outcome, as set by ExpectedOutcome().
+func (step *Step) GetExpectedOutcome() string {
+	return step.expectedOutcome
+}
+
+// Rollback gives the step instructions for undoing it.
+//
+// In the procedure's Markdown documentation, the rollback instructions are noted in the step's
+// section. Procedure.RenderRollback collects the rollback instructions of all steps into a
+// standalone rollback procedure. s is massaged in the same way as the argument to Long.
+func (step *Step) Rollback(s string) {
+	step.rollback = step.trimDescription(s)
+}
+
+// GetRollback returns the step's rollback instructions, as set by Rollback().
+func (step *Step) GetRollback() string {
+	return step.rollback
+}
+
+// An ExternalLink is a link from a step to external documentation, as set by Step.Link.
+type ExternalLink struct {
+	Label string
+	URL   string
+}
+
+// Link adds to the step a link to external documentation, such as a wiki page or a dashboard.
+//
+// Link may be called any number of times, and the links are kept in the order they were added. In
+// the procedure's Markdown documentation, they're rendered as a "**See also**" list of links in the
+// step's section. When the procedure is executed, they're shown to the user with their URLs
+// spelled out.
+func (step *Step) Link(label, url string) {
+	step.links = append(step.links, ExternalLink{Label: label, URL: url})
+}
+
+// GetLinks returns the step's links to external documentation, as set by Link().
+func (step *Step) GetLinks() []ExternalLink {
+	return step.links
+}
+
+// Automate gives the step an automated implementation.
+//
+// When the procedure is executed, an automated step is shown to the user as usual, but instead of
+// prompting the user to perform the step, donothing calls fn. fn can get the values of the step's
+// inputs, and must set the values of all the step's outputs, via its ExecContext argument. If fn
+// returns an error, execution stops. fn must return promptly once the context from
+// ExecContext.Context is done; see Timeout.
+//
+// This allows a do-nothing script to be automated gradually, one step at a time.
+func (step *Step) Automate(fn func(*ExecContext) error) {
+	step.fn = fn
+}
+
+// IsAutomated returns whether the step has an automated implementation, as set by Automate().
+func (step *Step) IsAutomated() bool {
+	return step.fn != nil
+}
+
+// Timeout limits how long the step's automated implementation may run.
+//
+// When the procedure is executed, the ExecContext passed to the implementation has a context that's
+// cancelled after d. If the implementation hasn't returned by then, execution stops with an error
+// wrapping context.DeadlineExceeded, whether or not the implementation heeds the context. An
+// implementation that ignores the context is left running in the background, so it should heed
+// it. Manual steps ignore the timeout. If d is 0, which is the default, there's no limit.
+func (step *Step) Timeout(d time.Duration) {
+	step.timeout = d
+}
+
+// GetTimeout returns the step's timeout, as set by Timeout().
+func (step *Step) GetTimeout() time.Duration {
+	return step.timeout
+}
+
+// BeforePrompt sets a callback to be run just before the user is prompted for the step.
+//
+// During execution, fn is called after the step and its inputs have been shown, right before the
+// user is prompted for the next action. For an automated step, it's called right before the step's
+// automated implementation. This is useful for instrumentation, such as emitting a metric when a
+// step starts. Outputs set through fn's ExecContext are ignored.
+func (step *Step) BeforePrompt(fn func(*ExecContext)) {
+	step.beforePrompt = fn
+}
+
+// AfterProceed sets a callback to be run once the user has proceeded past the step.
+//
+// During execution, fn is called once the user has proceeded and entered the step's outputs. For
+// an automated step, it's called once the step's automated implementation has returned
+// successfully and its outputs have been recorded. fn isn't called if the step is skipped or
+// fails. Outputs set through fn's ExecContext are ignored.
+func (step *Step) AfterProceed(fn func(*ExecContext)) {
+	step.afterProceed = fn
+}
+
 // AddStep adds a child step to the Step.
 //
 // A new Step will be instantiated and passed to fn, which is responsible for defining the new child
@@ -248,6 +452,60 @@ func (step *Step) AddStep(fn func(*Step)) {
 	step.children = append(step.children, newStep)
 }
 
+// AddProcedure adds the given procedure to the Step as a child step.
+//
+// The root step of sub becomes a child of the Step, with the given name, so sub's steps and their
+// descendants become part of the Step's procedure. sub should not be used on its own afterward.
+//
+// AddProcedure returns an error, and leaves both trees unchanged, if name is empty, contains dots
+// or whitespace, or is already the name of one of the Step's children; if any step in sub already
+// appears in the Step's tree (which would create a cycle); or if sub has already been added to
+// another step.
+func (step *Step) AddProcedure(name string, sub *Procedure) error {
+	if sub == nil {
+		return fmt.Errorf("failed to add procedure: procedure must not be nil")
+	}
+	if name == "" {
+		return fmt.Errorf("failed to add procedure: name must not be empty")
+	}
+	if strings.ContainsAny(name, ". \t\n") {
+		return fmt.Errorf("failed to add procedure as '%s': name must not contain dots or whitespace", name)
+	}
+	for _, child := range step.children {
+		if child.name == name {
+			return fmt.Errorf("failed to add procedure as '%s': step '%s' already exists", name, child.AbsoluteName())
+		}
+	}
+	if sub.rootStep.parent != nil {
+		return fmt.Errorf("failed to add procedure as '%s': procedure has already been added to step '%s'", name, sub.rootStep.parent.AbsoluteName())
+	}
+
+	top := step
+	for top.parent != nil {
+		top = top.parent
+	}
+	inTree := make(map[*Step]bool)
+	top.Walk(func(s *Step) error {
+		inTree[s] = true
+		return nil
+	})
+
+	err := sub.rootStep.Walk(func(s *Step) error {
+		if inTree[s] {
+			return fmt.Errorf("failed to add procedure as '%s': step '%s' already appears in the tree", name, s.AbsoluteName())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sub.rootStep.Name(name)
+	sub.rootStep.parent = step
+	step.children = append(step.children, sub.rootStep)
+	return nil
+}
+
 // OutputString specifies a string output to be produced by the step.
 //
 // name is the output's name, which must be unique within the procedure. If any two outputs have the
@@ -262,6 +520,66 @@ func (step *Step) OutputString(name string, desc string) {
 	step.outputs = append(step.outputs, output)
 }
 
+// OutputStringSecret specifies a secret string output to be produced by the step.
+//
+// A secret output behaves like one specified with OutputString, except that its value is never
+// displayed once the user has entered it, and it's redacted from the procedure's run report. The
+// terminal still echoes the value as the user types it; see Procedure.Execute.
+func (step *Step) OutputStringSecret(name string, desc string) {
+	output := NewOutputDef("string", name, desc)
+	output.Secret = true
+	step.outputs = append(step.outputs, output)
+}
+
+// OutputStringExample specifies a string output to be produced by the step, along with an example
+// of its value.
+//
+// The example is shown in the Outputs section of the procedure's rendered documentation as "e.g.
+// `example`", and it's included in the prompt when the user is asked for the output's value.
+//
+// name and desc have the same meaning as for OutputString.
+func (step *Step) OutputStringExample(name string, desc string, example string) {
+	output := NewOutputDef("string", name, desc)
+	output.Example = example
+	step.outputs = append(step.outputs, output)
+}
+
+// OutputStringOptional specifies a string output to be produced by the step, which may be left
+// empty.
+//
+// When the procedure is executed, the user may enter an empty value for the output. The output is
+// noted as optional in the procedure's rendered documentation. Since the value may be empty, any
+// required input that refers to the output will cause Procedure.Check() to return an error.
+//
+// name and desc have the same meaning as for OutputString.
+func (step *Step) OutputStringOptional(name string, desc string) {
+	output := NewOutputDef("string", name, desc)
+	output.Optional = true
+	step.outputs = append(step.outputs, output)
+}
+
+// OutputStringList specifies a string list output to be produced by the step.
+//
+// A string list output holds any number of lines of text, such as the names of the hosts affected
+// by an incident. When the procedure is executed, the user is prompted to enter the list one item
+// per line. A later step can use RepeatFor to run once for each item in the list.
+//
+// name and desc have the same meaning as for OutputString.
+func (step *Step) OutputStringList(name string, desc string) {
+	output := NewOutputDef("stringlist", name, desc)
+	step.outputs = append(step.outputs, output)
+}
+
+// Output specifies an output of the given type to be produced by the step.
+//
+// valueType must be one of the supported value types: "string", "stringlist", or "int". If it
+// isn't, the procedure will fail at the Check step, and Validate will report a problem. name and
+// desc have the same meaning as for OutputString.
+func (step *Step) Output(valueType string, name string, desc string) {
+	output := NewOutputDef(valueType, name, desc)
+	step.outputs = append(step.outputs, output)
+}
+
 // GetOutputDefs returns the step's output definitions.
 func (step *Step) GetOutputDefs() []OutputDef {
 	return step.outputs
@@ -276,6 +594,118 @@ func (step *Step) InputString(name string, required bool) {
 	step.inputs = append(step.inputs, input)
 }
 
+// Input specifies an input of the given type taken by the step.
+//
+// valueType must be one of the supported value types, as for Output. name must match the name of
+// an output of the same type from a previous step. If it doesn't, the procedure will fail at the
+// Check step.
+func (step *Step) Input(valueType string, name string, required bool) {
+	input := NewInputDef(valueType, name, required)
+	step.inputs = append(step.inputs, input)
+}
+
+// RepeatFor specifies that the step should be repeated for each item in a string list input.
+//
+// name must match the name of a string list output (see OutputStringList) from a previous step. If
+// it doesn't, the procedure will fail at the Check step.
+//
+// When the procedure is executed, the step and its descendants are executed once for each item in
+// the list, and the item for the current iteration is shown to the user along with the step's other
+// inputs.
+func (step *Step) RepeatFor(name string) {
+	input := NewInputDef("stringlist", name, true)
+	step.inputs = append(step.inputs, input)
+	step.repeatFor = name
+}
+
+// GetRepeatFor returns the name of the list input over which the step repeats, as set by
+// RepeatFor(). If the step doesn't repeat, GetRepeatFor returns "".
+func (step *Step) GetRepeatFor() string {
+	return step.repeatFor
+}
+
+// A Branch is a yes/no question that decides which of two of a step's children is executed.
+type Branch struct {
+	// The question asked after the step.
+	Question string
+	// The name of the child that's executed if the answer is yes.
+	YesStep string
+	// The name of the child that's executed if the answer is no.
+	NoStep string
+}
+
+// Branch makes the step ask a yes/no question that decides which of two of its children to
+// execute.
+//
+// yesStep and noStep are the names (not absolute names) of two of the step's children. When the
+// procedure is executed, the user is asked question once they've proceeded past the step. The
+// child for their answer is then executed, and the other child is skipped along with its
+// descendants. The step's other children are unaffected. In the procedure's Markdown
+// documentation, the question is noted along with links to both branches.
+//
+// If yesStep and noStep aren't the names of two different children of the step, the procedure
+// will fail at the Check step.
+func (step *Step) Branch(question string, yesStep string, noStep string) {
+	step.branch = &Branch{Question: question, YesStep: yesStep, NoStep: noStep}
+}
+
+// GetBranch returns the step's branch, as set by Branch(), or nil if the step doesn't branch.
+func (step *Step) GetBranch() *Branch {
+	return step.branch
+}
+
+// ReferenceOutput specifies that the step refers to the given output for context.
+//
+// Unlike an input, a reference imposes no requirements on the order of steps: the referenced output
+// may be produced by any step in the procedure. In the procedure's Markdown documentation, the
+// reference is rendered as a link to the section of the step that produces the output.
+//
+// name must match the name of an output of some step in the procedure. If it doesn't, the procedure
+// will fail at the Check step.
+func (step *Step) ReferenceOutput(name string) {
+	step.references = append(step.references, name)
+}
+
+// GetReferences returns the names of the outputs referenced by the step, as set by
+// ReferenceOutput().
+func (step *Step) GetReferences() []string {
+	return step.references
+}
+
+// PromptOrder sets the order in which the user is prompted for the step's inputs during execution.
+//
+// By default, inputs without values are prompted for (and inputs are listed) in the order they
+// were declared. The inputs named in names come first, in the order given, followed by the rest in
+// declaration order. Names that don't match any of the step's inputs are ignored.
+func (step *Step) PromptOrder(names ...string) {
+	step.promptOrder = names
+}
+
+// GetPromptOrder returns the names of inputs passed to PromptOrder().
+func (step *Step) GetPromptOrder() []string {
+	return step.promptOrder
+}
+
+// orderedInputDefs returns the step's input definitions in prompt order, as set by PromptOrder().
+func (step *Step) orderedInputDefs() []InputDef {
+	ordered := make([]InputDef, 0, len(step.inputs))
+	used := make(map[int]bool)
+	for _, name := range step.promptOrder {
+		for i, inputDef := range step.inputs {
+			if !used[i] && inputDef.Name == name {
+				ordered = append(ordered, inputDef)
+				used[i] = true
+			}
+		}
+	}
+	for i, inputDef := range step.inputs {
+		if !used[i] {
+			ordered = append(ordered, inputDef)
+		}
+	}
+	return ordered
+}
+
 // GetInputDefs returns the step's input definitions.
 func (step *Step) GetInputDefs() []InputDef {
 	return step.inputs
@@ -286,6 +716,127 @@ func (step *Step) GetChildren() []*Step {
 	return step.children
 }
 
+// Validate checks the step and its descendants for problems, without the need for a Procedure.
+//
+// This is useful for checking steps that are built in isolation, before they're added to a
+// procedure. It checks that:
+//
+//   1. Every step has a unique absolute name with no empty parts. If the step has no parent, its
+//      name must contain no dots or whitespace.
+//   2. Every step has a short description, and every input and output has a supported type.
+//   3. Every input that refers to an output from within the subtree comes after the step that
+//      produces the output, and has the same type as the output.
+//
+// Inputs that don't refer to any output from within the subtree are assumed to be satisfied by
+// the procedure the subtree will be added to. Procedure.Check performs the same checks, and more,
+// on the whole procedure. If there are no problems, an empty slice is returned.
+func (step *Step) Validate() []string {
+	problems := make([]string, 0)
+	steps := make(map[string]*Step)
+	step.Walk(func(s *Step) error {
+		problems = append(problems, s.checkStructure(steps)...)
+		steps[s.AbsoluteName()] = s
+		return nil
+	})
+
+	// The producers of all the outputs in the subtree, and of the outputs seen so far in walk
+	// order, keyed by output name.
+	allProducers := make(map[string]*Step)
+	step.Walk(func(s *Step) error {
+		for _, outputDef := range s.GetOutputDefs() {
+			if allProducers[outputDef.Name] == nil {
+				allProducers[outputDef.Name] = s
+			}
+		}
+		return nil
+	})
+	outputs := make(map[string]OutputDef)
+	step.Walk(func(s *Step) error {
+		for _, inputDef := range s.GetInputDefs() {
+			outputDef, ok := outputs[inputDef.Name]
+			if !ok {
+				if producer := allProducers[inputDef.Name]; producer != nil {
+					problems = append(problems, fmt.Sprintf(
+						"Input '%s' of step '%s' refers to an output of step '%s', which comes after it",
+						inputDef.Name,
+						s.AbsoluteName(),
+						producer.AbsoluteName(),
+					))
+				}
+				continue
+			}
+			if outputDef.ValueType != inputDef.ValueType {
+				problems = append(problems, fmt.Sprintf(
+					"Input '%s' of step '%s' has type '%s', but output '%s' has type '%s'",
+					inputDef.Name,
+					s.AbsoluteName(),
+					inputDef.ValueType,
+					outputDef.Name,
+					outputDef.ValueType,
+				))
+			}
+		}
+		for _, outputDef := range s.GetOutputDefs() {
+			outputs[outputDef.Name] = outputDef
+		}
+		return nil
+	})
+
+	return problems
+}
+
+// checkStructure returns the problems with the step's name and short description.
+//
+// steps holds the steps seen so far, keyed by absolute name, so that duplicate names can be
+// detected.
+func (step *Step) checkStructure(steps map[string]*Step) []string {
+	problems := make([]string, 0)
+	absName := step.AbsoluteName()
+	if step.name == "" {
+		if step.parent == nil {
+			// For a procedure, this can only happen if the calling code passed "" to SetRootName.
+			problems = append(problems, "Root step does not have name")
+		} else {
+			problems = append(problems, fmt.Sprintf("Child step of '%s' does not have name", step.parent.AbsoluteName()))
+		}
+	} else if step.parent == nil && strings.ContainsAny(step.name, ". \t\n") {
+		problems = append(problems, fmt.Sprintf("Root step name '%s' must not contain dots or whitespace", step.name))
+	}
+
+	if steps[absName] != nil {
+		problems = append(problems, fmt.Sprintf("More than one step with name '%s'", absName))
+	}
+
+	if step.GetShort() == "" {
+		problems = append(problems, fmt.Sprintf("Step '%s' has no Short value", absName))
+	}
+
+	for _, inputDef := range step.GetInputDefs() {
+		if !isValueType(inputDef.ValueType) {
+			problems = append(problems, fmt.Sprintf("Input '%s' of step '%s' has unknown type '%s'", inputDef.Name, absName, inputDef.ValueType))
+		}
+	}
+	for _, outputDef := range step.GetOutputDefs() {
+		if !isValueType(outputDef.ValueType) {
+			problems = append(problems, fmt.Sprintf("Output '%s' of step '%s' has unknown type '%s'", outputDef.Name, absName, outputDef.ValueType))
+		}
+	}
+	return problems
+}
+
+// valueTypes lists the supported types for the values of inputs and outputs.
+var valueTypes = []string{"string", "stringlist", "int"}
+
+// isValueType returns whether valueType is one of the supported value types.
+func isValueType(valueType string) bool {
+	for _, t := range valueTypes {
+		if t == valueType {
+			return true
+		}
+	}
+	return false
+}
+
 // Walk visits every step in the tree, calling fn on each.
 //
 // It's a depth-first walk, starting with step itself, then proceeding in sequence through the
@@ -300,10 +851,32 @@ func (step *Step) Walk(fn func(*Step) error) error {
 	if err := fn(step); err != nil {
 		return err
 	}
-	for _, childStep := range step.children {
-		if err := childStep.Walk(fn); err != nil && err != NoRecurse {
+
+	// Walk iteratively rather than recursively, so that very deep trees don't use up the stack.
+	// Each frame holds a step whose children are being visited, and the index of the next child
+	// to visit.
+	type frame struct {
+		step *Step
+		next int
+	}
+	stack := []frame{frame{step: step}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next >= len(top.step.children) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		childStep := top.step.children[top.next]
+		top.next++
+
+		err := fn(childStep)
+		if err == NoRecurse {
+			continue
+		}
+		if err != nil {
 			return err
 		}
+		stack = append(stack, frame{step: childStep})
 	}
 	return nil
 }
@@ -317,5 +890,6 @@ func NewStep() *Step {
 	step.children = make([]*Step, 0)
 	step.inputs = make([]InputDef, 0)
 	step.outputs = make([]OutputDef, 0)
+	step.references = make([]string, 0)
 	return step
 }
@@ -1,7 +1,11 @@
 package donothing
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -75,3 +79,286 @@ func TestStep_Pos_MissingFromParent(t *testing.T) {
 		_ = fooStep.Pos()
 	})
 }
+
+// AddProcedure should graft another procedure's steps into the tree.
+func TestStep_AddProcedure(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	sub := NewProcedure()
+	sub.Short("Sub-procedure")
+	sub.AddStep(func(step *Step) {
+		step.Name("subStep")
+		step.Short("Step of sub-procedure")
+	})
+
+	pcd := NewProcedure()
+	pcd.Short("Main procedure")
+	assert.Nil(pcd.AddProcedure("included", sub))
+
+	step, err := pcd.GetStepByName("root.included.subStep")
+	assert.Nil(err)
+	assert.Equal([]int{0, 0}, step.Pos())
+
+	// Adding the same procedure again, anywhere, is an error
+	other := NewProcedure()
+	assert.NotNil(other.AddProcedure("included", sub))
+}
+
+// AddProcedure should refuse to add a procedure's own subtree back into itself.
+func TestStep_AddProcedure_Cycle(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Main procedure")
+	pcd.AddStep(func(step *Step) {
+		step.Name("child")
+		step.Short("Child step")
+	})
+
+	// The procedure into itself
+	err := pcd.AddProcedure("self", pcd)
+	assert.NotNil(err)
+
+	// The procedure into one of its own descendants
+	child, err := pcd.GetStepByName("root.child")
+	assert.Nil(err)
+	err = child.AddProcedure("self", pcd)
+	assert.NotNil(err)
+
+	// The tree should be unchanged, and walking it should terminate
+	n := 0
+	assert.Nil(pcd.rootStep.Walk(func(step *Step) error {
+		n++
+		return nil
+	}))
+	assert.Equal(2, n)
+}
+
+// AddProcedure should refuse names that would make the procedure's absolute names invalid.
+func TestStep_AddProcedure_InvalidName(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	for i, name := range []string{"", "has.dot", "has space", "existing"} {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Main procedure")
+		pcd.AddStep(func(step *Step) {
+			step.Name("existing")
+			step.Short("Existing step")
+		})
+		sub := NewProcedure()
+		sub.Short("Sub-procedure")
+
+		assert.NotNil(pcd.AddProcedure(name, sub))
+		assert.Equal(1, len(pcd.rootStep.GetChildren()))
+		assert.Nil(sub.rootStep.parent)
+	}
+}
+
+// Each of a step's getters should return what was set with the corresponding setter.
+func TestStep_Getters(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var step *Step
+	pcd := NewProcedure()
+	pcd.AddStep(func(s *Step) {
+		step = s
+		s.Name("restartPod")
+		s.Short("Restart a pod")
+		s.Long("Delete the pod and let its deployment replace it.")
+		s.ExpectedOutcome("A new pod is running")
+		s.RepeatFor("PodNames")
+		s.InputString("Cluster", true)
+		s.PromptOrder("Cluster")
+		s.ReferenceOutput("Runbook")
+		s.OutputString("NewPodName", "Name of the replacement pod")
+		s.Timeout(30 * time.Second)
+		s.AddStep(func(s *Step) {
+			s.Name("checkPod")
+			s.Short("Check the pod")
+		})
+	})
+
+	assert.Equal("restartPod", step.GetName())
+	assert.Equal("root.restartPod", step.AbsoluteName())
+	assert.Equal("Restart a pod", step.GetShort())
+	assert.Equal("Delete the pod and let its deployment replace it.", step.GetLong())
+	assert.Equal("A new pod is running", step.GetExpectedOutcome())
+	assert.Equal("PodNames", step.GetRepeatFor())
+	assert.Equal(
+		[]InputDef{NewInputDef("stringlist", "PodNames", true), NewInputDef("string", "Cluster", true)},
+		step.GetInputDefs(),
+	)
+	assert.Equal([]string{"Cluster"}, step.GetPromptOrder())
+	assert.Equal([]string{"Runbook"}, step.GetReferences())
+	assert.Equal([]OutputDef{NewOutputDef("string", "NewPodName", "Name of the replacement pod")}, step.GetOutputDefs())
+	assert.Equal(30*time.Second, step.GetTimeout())
+	assert.False(step.IsAutomated())
+	if assert.Equal(1, len(step.GetChildren())) {
+		assert.Equal("checkPod", step.GetChildren()[0].GetName())
+	}
+
+	step.Automate(func(*ExecContext) error { return nil })
+	assert.True(step.IsAutomated())
+}
+
+// Validate should check a standalone subtree for structural problems and for inconsistent inputs
+// and outputs within it.
+func TestStep_Validate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		Build    func(*Step)
+		Problems []string
+	}
+
+	testCases := []testCase{
+		// Well-formed, with an input that must come from outside the subtree
+		testCase{
+			Build: func(step *Step) {
+				step.Name("restart")
+				step.Short("Restart the server")
+				step.InputString("HostName", true)
+				step.AddStep(func(step *Step) {
+					step.Name("stop")
+					step.Short("Stop the server")
+					step.OutputString("StopTime", "When the server stopped")
+				})
+				step.AddStep(func(step *Step) {
+					step.Name("start")
+					step.Short("Start the server")
+					step.InputString("StopTime", true)
+				})
+			},
+			Problems: []string{},
+		},
+		// Malformed
+		testCase{
+			Build: func(step *Step) {
+				step.Name("re start")
+				step.Short("Restart the server")
+				step.InputString("StopTime", true)
+				step.AddStep(func(step *Step) {
+					step.Name("stop")
+					step.OutputString("StopTime", "When the server stopped")
+					step.OutputString("PID", "Process ID of the server")
+				})
+				step.AddStep(func(step *Step) {
+					step.Short("Start the server")
+					step.RepeatFor("PID")
+				})
+			},
+			Problems: []string{
+				"Root step name 're start' must not contain dots or whitespace",
+				"Step 're start.stop' has no Short value",
+				"Child step of 're start' does not have name",
+				"Input 'StopTime' of step 're start' refers to an output of step 're start.stop', which comes after it",
+				"Input 'PID' of step 're start.' has type 'stringlist', but output 'PID' has type 'string'",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		step := NewStep()
+		tc.Build(step)
+		assert.Equal(tc.Problems, step.Validate())
+	}
+}
+
+// Output and Input should accept every supported value type, and Check should reject any other.
+func TestStep_OutputInput(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		ValueType string
+		Problems  []string
+	}
+
+	testCases := []testCase{
+		testCase{ValueType: "string", Problems: nil},
+		testCase{ValueType: "stringlist", Problems: nil},
+		testCase{ValueType: "int", Problems: nil},
+		testCase{
+			ValueType: "float",
+			Problems: []string{
+				"Output 'Thing' of step 'root.produce' has unknown type 'float'",
+				"Input 'Thing' of step 'root.consume' has unknown type 'float'",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+
+		pcd := NewProcedure()
+		pcd.Short("Move the thing")
+		pcd.AddStep(func(step *Step) {
+			step.Name("produce")
+			step.Short("Produce the thing")
+			step.Output(tc.ValueType, "Thing", "The thing")
+		})
+		pcd.AddStep(func(step *Step) {
+			step.Name("consume")
+			step.Short("Consume the thing")
+			step.Input(tc.ValueType, "Thing", true)
+		})
+
+		consume, err := pcd.GetStepByName("root.consume")
+		assert.Nil(err)
+		assert.Equal([]InputDef{NewInputDef(tc.ValueType, "Thing", true)}, consume.GetInputDefs())
+
+		problems, err := pcd.Check()
+		if tc.Problems == nil {
+			assert.Nil(err)
+			continue
+		}
+		assert.NotNil(err)
+		assert.Equal(tc.Problems, problems)
+	}
+}
+
+// A chain of steps as deep as DefaultMaxDepth should walk and render.
+func TestStep_DeepChain(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	const depth = DefaultMaxDepth
+
+	pcd := NewProcedure()
+	pcd.Short("Go deep")
+	var deepest *Step
+	parent := pcd.rootStep
+	for i := 0; i < depth; i++ {
+		child := NewStep()
+		child.parent = parent
+		child.Name(fmt.Sprintf("s%d", i))
+		child.Short(fmt.Sprintf("Step %d", i))
+		parent.children = append(parent.children, child)
+		parent = child
+		deepest = child
+	}
+
+	n := 0
+	assert.Nil(pcd.rootStep.Walk(func(step *Step) error {
+		n++
+		return nil
+	}))
+	assert.Equal(depth+1, n)
+	assert.Equal(depth, deepest.Depth())
+	assert.Equal(depth, len(deepest.Pos()))
+	assert.True(strings.HasPrefix(deepest.AbsoluteName(), "root.s0.s1.s2."))
+	assert.True(strings.HasSuffix(deepest.AbsoluteName(), fmt.Sprintf(".s%d", depth-1)))
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), fmt.Sprintf("Step %d\n", depth-1))
+}
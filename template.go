@@ -21,25 +21,57 @@ func AddTemplateDoc(tpl *template.Template) {
 func AddTemplateStep(tpl *template.Template) {
 	newTpl := tpl.New("step")
 	txt := `{{define "step" -}}
+{{if .StepComment}}<!-- step: {{.StepName}} -->
+{{end -}}
 {{.SectionHeader}}{{if .ParentAnchor}}
 
-@@{{.StepName}}@@
+@@{{.StepName}}@@{{range .CollapsedNames}}, @@{{.}}@@{{end}}
 •
 [Up]({{.ParentAnchor}}){{end}}{{if .Body}}
 
 {{.Body}}{{end -}}
+{{if .LongSteps}}
+
+{{.NumberedSteps}}{{end -}}
+{{if .ExpectedOutcome}}
+
+**Expected**: {{.ExpectedOutcome}}{{end -}}
+{{if .Rollback}}
+
+*Rollback*: {{.Rollback}}{{end -}}
+{{if .RepeatFor}}
+
+**Repeat** this step for each item in @@{{.RepeatFor}}@@.{{end -}}
+{{if .Branch}}
+
+**Branch**: {{.Branch.Question}} If yes, go to [@@{{.Branch.Yes.Name}}@@]({{.Branch.Yes.Anchor}}); if no, go to [@@{{.Branch.No.Name}}@@]({{.Branch.No.Anchor}}).{{end -}}
+{{if .References}}
+
+**References**: {{range $i, $ref := .References}}{{if $i}}, {{end -}}
+{{if .Anchor}}[@@{{.Name}}@@]({{.Anchor}}){{else}}@@{{.Name}}@@{{end}}{{end}}{{end -}}
+{{if .Links}}
+
+**See also**:
+{{range .Links}}
+  - [{{.Label}}]({{.URL}}){{end}}{{end -}}
 {{if .InputDefs}}
 
-{{template "inputs" .InputDefs}}{{end -}}
+{{if .InputTable}}{{template "inputs_table" .InputRows}}{{else if .GlossaryLinks}}{{template "inputs_linked" .InputRows}}{{else}}{{template "inputs" .InputDefs}}{{end}}{{end -}}
 {{if .OutputDefs}}
 
-{{template "outputs" .OutputDefs}}{{end -}}
+{{if .GlossaryLinks}}{{template "outputs_linked" .OutputRows}}{{else}}{{template "outputs" .OutputDefs}}{{end}}{{end -}}
+{{if .Variables}}
+
+{{template "variables" .Variables}}{{end -}}
 {{if eq .Depth 0}}
 
 {{template "table_of_contents" .Children}}{{end -}}
 {{range .Children}}
 
 {{template "step" .}}{{end -}}
+{{if .Glossary}}
+
+{{template "glossary" .}}{{end -}}
 {{end}}`
 	template.Must(newTpl.Parse(txt))
 }
@@ -48,7 +80,17 @@ func AddTemplateStep(tpl *template.Template) {
 func AddTemplateExecStep(tpl *template.Template) {
 	txt := `{{.SectionHeader}}{{if .Body}}
 
-{{.Body}}{{end -}}`
+{{.Body}}{{end -}}
+{{if .LongSteps}}
+
+{{.NumberedSteps}}{{end -}}
+{{if .ExpectedOutcome}}
+
+Expected: {{.ExpectedOutcome}}{{end -}}
+{{if .Links}}
+
+See also:{{range .Links}}
+  - {{.Label}}: {{.URL}}{{end}}{{end -}}`
 	template.Must(tpl.Parse(txt))
 }
 
@@ -68,6 +110,42 @@ func AddTemplateInputs(tpl *template.Template) {
 	template.Must(newTpl.Parse(txt))
 }
 
+// AddTemplateInputsTable adds the step inputs table template to the given template.
+//
+// This is the "**Inputs**" section of a step's documentation when the procedure's input style is
+// InputStyleTable. It takes as . a slice of InputRow instances.
+func AddTemplateInputsTable(tpl *template.Template) {
+	newTpl := tpl.New("inputs_table")
+	txt := `{{define "inputs_table" -}}
+{{if . -}}
+**Inputs**:
+
+| Name | Type | Required | Description |
+| --- | --- | --- | --- |
+{{- range .}}
+| {{if .Anchor}}[@@{{.Name}}@@]({{.Anchor}}){{else}}@@{{.Name}}@@{{end}} | {{.ValueType}} | {{if .Required}}yes{{else}}no{{end}} | {{.Description}} |
+{{- end -}}
+{{end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
+// AddTemplateInputsLinked adds the linked step inputs template to the given template.
+//
+// This is the "**Inputs**" section of a step's documentation when the procedure has a glossary. It
+// takes as . a slice of InputRow instances, and links each input that has an Anchor to it.
+func AddTemplateInputsLinked(tpl *template.Template) {
+	newTpl := tpl.New("inputs_linked")
+	txt := `{{define "inputs_linked" -}}
+{{if . -}}
+**Inputs**:
+{{range .}}
+  - {{if .Anchor}}[@@{{.Name}}@@]({{.Anchor}}){{else}}@@{{.Name}}@@{{end}}{{end -}}
+{{end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
 // AddTemplateOutputs adds the step outputs template to the given template.
 //
 // This is the "**Outputs**" section of a step's documentation. It takes as . a slice of OutputDef
@@ -78,12 +156,68 @@ func AddTemplateOutputs(tpl *template.Template) {
 {{if . -}}
 **Outputs**:
 {{range .}}
-  - @@{{.Name}}@@ ({{.ValueType}}): {{.Short}}{{end -}}
+  - @@{{.Name}}@@ ({{.ValueType}}): {{.Short}}{{if .Optional}} (optional){{end}}{{if .Example}} (e.g. @@{{.Example}}@@){{end}}{{end -}}
 {{else -}}{{end -}}
 {{end}}`
 	template.Must(newTpl.Parse(txt))
 }
 
+// AddTemplateOutputsLinked adds the linked step outputs template to the given template.
+//
+// This is the "**Outputs**" section of a step's documentation when the procedure has a glossary.
+// It takes as . a slice of OutputRow instances, and links each output to its glossary entry.
+func AddTemplateOutputsLinked(tpl *template.Template) {
+	newTpl := tpl.New("outputs_linked")
+	txt := `{{define "outputs_linked" -}}
+{{if . -}}
+**Outputs**:
+{{range .}}
+  - [@@{{.Name}}@@]({{.Anchor}}) ({{.ValueType}}): {{.Short}}{{if .Optional}} (optional){{end}}{{if .Example}} (e.g. @@{{.Example}}@@){{end}}{{end -}}
+{{end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
+// AddTemplateGlossary adds the glossary template to the given template.
+//
+// This is the glossary section at the end of a procedure's documentation. It takes as . the
+// StepTemplateData for the top of the document.
+func AddTemplateGlossary(tpl *template.Template) {
+	newTpl := tpl.New("glossary")
+	txt := `{{define "glossary" -}}
+{{.GlossaryHeader}}
+{{- range .Glossary}}
+
+{{.Header}}
+
+{{.Short}}
+
+  - **Type**: {{.ValueType}}
+  - **Produced by**: [@@{{.Producer.Name}}@@]({{.Producer.Anchor}})
+  - **Consumed by**: {{range $i, $c := .Consumers}}{{if $i}}, {{end}}[@@{{.Name}}@@]({{.Anchor}}){{else}}none{{end}}
+{{- end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
+// AddTemplateVariables adds the variables table template to the given template.
+//
+// This is the "**Variables**" table in the opening section of a procedure's documentation. It takes
+// as . a slice of VariableRow instances.
+func AddTemplateVariables(tpl *template.Template) {
+	newTpl := tpl.New("variables")
+	txt := `{{define "variables" -}}
+**Variables**:
+
+| Name | Type | Produced by | Consumed by |
+| --- | --- | --- | --- |
+{{- range .}}
+| @@{{.Name}}@@ | {{.ValueType}} | [@@{{.Producer.Name}}@@]({{.Producer.Anchor}}) | {{range $i, $c := .Consumers}}{{if $i}}, {{end}}[@@{{.Name}}@@]({{.Anchor}}){{else}}none{{end}} |
+{{- end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
 // AddTemplateTableOfContents adds the table of contents template to the given template.
 func AddTemplateTableOfContents(tpl *template.Template) {
 	newTpl := tpl.New("table_of_contents")
@@ -113,7 +247,12 @@ func DocTemplate() (*template.Template, error) {
 	AddTemplateDoc(tpl)
 	AddTemplateStep(tpl)
 	AddTemplateInputs(tpl)
+	AddTemplateInputsTable(tpl)
+	AddTemplateInputsLinked(tpl)
 	AddTemplateOutputs(tpl)
+	AddTemplateOutputsLinked(tpl)
+	AddTemplateVariables(tpl)
+	AddTemplateGlossary(tpl)
 	AddTemplateTableOfContents(tpl)
 
 	return tpl, nil
@@ -126,25 +265,271 @@ func ExecTemplate() (*template.Template, error) {
 	return tpl, nil
 }
 
+// AddTemplateAsciiDocStep adds to the given template the AsciiDoc template with which we render a
+// Step.
+//
+// This is the AsciiDoc counterpart of the "step" template added by AddTemplateStep. The input
+// passed as . is an instance of StepTemplateData. The template uses the "adocID" function, which
+// AsciiDocTemplate provides.
+func AddTemplateAsciiDocStep(tpl *template.Template) {
+	newTpl := tpl.New("adoc_step")
+	txt := `{{define "adoc_step" -}}
+[[{{adocID .Anchor}}]]
+{{.AsciiDocSectionHeader}}{{if .ParentAnchor}}
+
+@@{{.StepName}}@@{{range .CollapsedNames}}, @@{{.}}@@{{end}} • <<{{adocID .ParentAnchor}},Up>>{{end}}{{if .Body}}
+
+{{.Body}}{{end -}}
+{{if .LongSteps}}
+{{range .LongSteps}}
+. {{.}}{{end}}{{end -}}
+{{if .ExpectedOutcome}}
+
+*Expected*: {{.ExpectedOutcome}}{{end -}}
+{{if .RepeatFor}}
+
+*Repeat* this step for each item in @@{{.RepeatFor}}@@.{{end -}}
+{{if .References}}
+
+*References*: {{range $i, $ref := .References}}{{if $i}}, {{end -}}
+{{if .Anchor}}<<{{adocID .Anchor}},@@{{.Name}}@@>>{{else}}@@{{.Name}}@@{{end}}{{end}}{{end -}}
+{{if .InputDefs}}
+
+{{template "adoc_inputs" .InputDefs}}{{end -}}
+{{if .OutputDefs}}
+
+{{template "adoc_outputs" .OutputDefs}}{{end -}}
+{{range .Children}}
+
+{{template "adoc_step" .}}{{end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
+// AddTemplateAsciiDocInputs adds the AsciiDoc step inputs template to the given template.
+//
+// This is the labeled "Inputs" block of a step's AsciiDoc documentation. It takes as . a slice of
+// InputDef instances.
+func AddTemplateAsciiDocInputs(tpl *template.Template) {
+	newTpl := tpl.New("adoc_inputs")
+	txt := `{{define "adoc_inputs" -}}
+{{if . -}}
+.Inputs
+{{- range .}}
+* @@{{.Name}}@@{{end -}}
+{{end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
+// AddTemplateAsciiDocOutputs adds the AsciiDoc step outputs template to the given template.
+//
+// This is the labeled "Outputs" block of a step's AsciiDoc documentation. It takes as . a slice of
+// OutputDef instances.
+func AddTemplateAsciiDocOutputs(tpl *template.Template) {
+	newTpl := tpl.New("adoc_outputs")
+	txt := `{{define "adoc_outputs" -}}
+{{if . -}}
+.Outputs
+{{- range .}}
+* @@{{.Name}}@@ ({{.ValueType}}): {{.Short}}{{if .Optional}} (optional){{end}}{{if .Example}} (e.g. @@{{.Example}}@@){{end}}{{end -}}
+{{end -}}
+{{end}}`
+	template.Must(newTpl.Parse(txt))
+}
+
+// AsciiDocTemplate returns the template for an AsciiDoc document.
+func AsciiDocTemplate() (*template.Template, error) {
+	tpl := template.New("adoc")
+	tpl.Funcs(template.FuncMap{
+		// Converts a Markdown anchor, like "#0-find-the-host", to an AsciiDoc ID, like
+		// "_0-find-the-host". AsciiDoc IDs can't start with a digit.
+		"adocID": func(anchor string) string {
+			return "_" + strings.TrimPrefix(anchor, "#")
+		},
+	})
+	template.Must(tpl.Parse(`{{template "adoc_step" .}}
+`))
+	AddTemplateAsciiDocStep(tpl)
+	AddTemplateAsciiDocInputs(tpl)
+	AddTemplateAsciiDocOutputs(tpl)
+
+	return tpl, nil
+}
+
 // StepTemplateData is the thing that gets passed to a step template on evaluation.
 type StepTemplateData struct {
-	Depth      int
-	Pos        []int
-	StepName   string
-	Title      string
-	Body       string
+	Depth    int
+	Pos      []int
+	StepName string
+	// The absolute names of the steps that were collapsed into this one, if any. See
+	// Procedure.SetCollapseSingleChildChains.
+	CollapsedNames []string
+	Title          string
+	Body           string
+	// Whether Body was set by Step.LongLiteral. If it was, its backtick standins are escaped.
+	LiteralBody bool
+	// The step's ordered list of actions, as set by Step.LongSteps
+	LongSteps []string
+	// The step's expected outcome, as set by Step.ExpectedOutcome
+	ExpectedOutcome string
+	// The step's rollback instructions, as set by Step.Rollback
+	Rollback string
+	// The name of the list input over which the step repeats, as set by Step.RepeatFor
+	RepeatFor string
+	// The step's branch, as set by Step.Branch, or nil if the step doesn't branch
+	Branch     *BranchTemplateData
+	References []OutputReference
+	// The step's links to external documentation, as set by Step.Link
+	Links      []ExternalLink
 	InputDefs  []InputDef
 	OutputDefs []OutputDef
 	Parent     *StepTemplateData
 	Children   []StepTemplateData
+
+	// The step's inputs and outputs, along with the extra information needed to render them as a
+	// table (see Procedure.SetInputStyle) or with links to the glossary (see
+	// Procedure.SetGlossary). nil unless one of those options is enabled.
+	InputRows  []InputRow
+	OutputRows []OutputRow
+	// Whether to render the step's inputs as a table. See Procedure.SetInputStyle.
+	InputTable bool
+	// Whether to link the step's inputs and outputs to the glossary. See Procedure.SetGlossary.
+	GlossaryLinks bool
+
+	// The number of levels by which to shift the section header down. See
+	// Procedure.SetHeadingOffset.
+	HeadingOffset int
+	// Whether to precede the section header with an HTML comment giving the step's absolute name.
+	// See Procedure.SetStepComments.
+	StepComment bool
+	// The rows of the variables table, if there is one. See Procedure.SetVariablesTable.
+	Variables []VariableRow
+	// The header and entries of the glossary, if there is one. See Procedure.SetGlossary.
+	GlossaryHeader string
+	Glossary       []GlossaryEntry
+}
+
+// InputRow is a row of a step's inputs table, as described in Procedure.SetInputStyle.
+type InputRow struct {
+	InputDef
+	// The short description of the output that the input refers to, or "" if there's no such
+	// output.
+	Description string
+	// The anchor of the glossary entry for the input, or "" if there's no such entry.
+	Anchor string
+}
+
+// OutputRow is an output of a step, along with the anchor of its glossary entry.
+type OutputRow struct {
+	OutputDef
+	Anchor string
+}
+
+// newInputRows returns the inputs table rows for inputDefs.
+//
+// descriptions maps output names to their short descriptions. anchors maps output names to the
+// anchors of their glossary entries.
+func newInputRows(inputDefs []InputDef, descriptions map[string]string, anchors map[string]string) []InputRow {
+	rows := make([]InputRow, len(inputDefs))
+	for i, inputDef := range inputDefs {
+		rows[i] = InputRow{
+			InputDef:    inputDef,
+			Description: strings.Replace(descriptions[inputDef.Name], "|", `\|`, -1),
+			Anchor:      anchors[inputDef.Name],
+		}
+	}
+	return rows
+}
+
+// setRows fills in the InputRows and OutputRows of td and all of its descendants.
+//
+// descriptions and anchors have the same meaning as for newInputRows. If anchors is nil, the rows
+// aren't linked to the glossary. table says whether inputs are rendered as a table.
+func (td *StepTemplateData) setRows(descriptions map[string]string, anchors map[string]string, table bool) {
+	td.InputRows = newInputRows(td.InputDefs, descriptions, anchors)
+	td.OutputRows = make([]OutputRow, len(td.OutputDefs))
+	for i, outputDef := range td.OutputDefs {
+		td.OutputRows[i] = OutputRow{OutputDef: outputDef, Anchor: anchors[outputDef.Name]}
+	}
+	td.InputTable = table
+	td.GlossaryLinks = anchors != nil
+	for i := range td.Children {
+		td.Children[i].setRows(descriptions, anchors, table)
+	}
+}
+
+// VariableRow is a row of the variables table, as described in Procedure.SetVariablesTable.
+type VariableRow struct {
+	Name      string
+	ValueType string
+	// The output's short description.
+	Short string
+	// The step that produces the output.
+	Producer StepLink
+	// The steps that take the output as an input, in procedure order.
+	Consumers []StepLink
+}
+
+// BranchTemplateData describes a step's branch, as set by Step.Branch.
+type BranchTemplateData struct {
+	Question string
+	// The children executed if the answer is yes and no, respectively.
+	Yes StepLink
+	No  StepLink
+}
+
+// StepLink identifies a step's section in the rendered documentation.
+type StepLink struct {
+	// The step's absolute name.
+	Name string
+	// The anchor of the step's section.
+	Anchor string
+}
+
+// OutputReference is a reference from a step to an output, as set by Step.ReferenceOutput.
+type OutputReference struct {
+	// The name of the referenced output.
+	Name string
+	// The anchor of the section for the step that produces the output, or "" if that step isn't
+	// being rendered.
+	Anchor string
 }
 
 // SectionHeader returns the header line for the step's section.
 //
 // For example, "## (0.2) Short description of step"
+//
+// The header level is the step's depth plus one, shifted down by HeadingOffset levels. Since
+// Markdown has only six levels of header, the level is clamped between 1 and 6.
 func (td StepTemplateData) SectionHeader() string {
-	// Header prefix; e.g. "###"
-	parts := []string{strings.Repeat("#", td.Depth+1)}
+	return fmt.Sprintf("%s %s", strings.Repeat("#", td.headingLevel()), td.headingText())
+}
+
+// AsciiDocSectionHeader returns the AsciiDoc header line for the step's section.
+//
+// For example, "=== (0.2) Short description of step". The level is the same as in SectionHeader.
+func (td StepTemplateData) AsciiDocSectionHeader() string {
+	return fmt.Sprintf("%s %s", strings.Repeat("=", td.headingLevel()), td.headingText())
+}
+
+// headingLevel returns the level of the step's section header, as described in SectionHeader.
+func (td StepTemplateData) headingLevel() int {
+	level := td.Depth + 1 + td.HeadingOffset
+	if level > 6 {
+		level = 6
+	}
+	if level < 1 {
+		level = 1
+	}
+	return level
+}
+
+// headingText returns the text of the step's section header, without any Markdown header prefix.
+//
+// For example, "(0.2) Short description of step"
+func (td StepTemplateData) headingText() string {
+	parts := make([]string, 0)
 
 	// Numeric path part; e.g. "(0.2.1)". Absent if root step.
 	if td.Depth > 0 {
@@ -177,8 +562,18 @@ func (td StepTemplateData) ParentAnchor() string {
 // headers to anchors:
 // https://github.com/gjtorikian/html-pipeline/blob/main/lib/html/pipeline/toc_filter.rb
 func (td StepTemplateData) Anchor() string {
+	return headerAnchor(td.SectionHeader())
+}
+
+// anchorChar matches a character that's allowed in an anchor. It's compiled once, since
+// headerAnchor is called for every section and link in a rendered document.
+var anchorChar = regexp.MustCompile(`[[:alnum:]- ]`)
+
+// headerAnchor returns the href for the given Markdown header line, as described in
+// StepTemplateData.Anchor.
+func headerAnchor(header string) string {
 	// Convert header to lowercase
-	s0 := strings.ToLower(td.SectionHeader())
+	s0 := strings.ToLower(header)
 	// Remove header indicators (e.g. ###)
 	s1 := strings.TrimLeft(s0, "#")
 	// Remove initial space (the space that occurs after the header indicators)
@@ -188,7 +583,7 @@ func (td StepTemplateData) Anchor() string {
 		strings.FieldsFunc(
 			s2,
 			func(char rune) bool {
-				return ("" == regexp.MustCompile(`[[:alnum:]- ]`).FindString(string(char)))
+				return ("" == anchorChar.FindString(string(char)))
 			},
 		),
 		"",
@@ -199,11 +594,276 @@ func (td StepTemplateData) Anchor() string {
 	return fmt.Sprintf("#%s", s4)
 }
 
+// NumberedSteps returns the step's ordered list of actions as a numbered Markdown list.
+func (td StepTemplateData) NumberedSteps() string {
+	lines := make([]string, len(td.LongSteps))
+	for i, item := range td.LongSteps {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, item)
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Returns the indent that should prefix the step's table of contents line.
 func (td StepTemplateData) TOCIndent() string {
 	return strings.Repeat("    ", td.Depth-1)
 }
 
+// linkReferences sets the Anchor of each OutputReference in td and its descendants.
+//
+// Each reference is linked to the section of the step that produces the referenced output, if
+// that step is among td and its descendants. The anchors of each branch's steps are set as well.
+func (td *StepTemplateData) linkReferences() {
+	anchors := make(map[string]string)
+	var findOutputs func(StepTemplateData)
+	findOutputs = func(d StepTemplateData) {
+		for _, outputDef := range d.OutputDefs {
+			anchors[outputDef.Name] = d.Anchor()
+		}
+		for _, c := range d.Children {
+			findOutputs(c)
+		}
+	}
+	findOutputs(*td)
+
+	var link func(*StepTemplateData)
+	link = func(d *StepTemplateData) {
+		for i := range d.References {
+			d.References[i].Anchor = anchors[d.References[i].Name]
+		}
+		if d.Branch != nil {
+			// Each branch is a child of the step, so it's always being rendered.
+			branch := *d.Branch
+			for _, c := range d.Children {
+				if c.StepName == branch.Yes.Name {
+					branch.Yes.Anchor = c.Anchor()
+				}
+				if c.StepName == branch.No.Name {
+					branch.No.Anchor = c.Anchor()
+				}
+			}
+			d.Branch = &branch
+		}
+		for i := range d.Children {
+			link(&d.Children[i])
+		}
+	}
+	link(td)
+}
+
+// GlossaryEntry is an entry in the glossary, as described in Procedure.SetGlossary.
+type GlossaryEntry struct {
+	VariableRow
+	// The entry's header line, and its anchor.
+	Header string
+	Anchor string
+}
+
+// setVariables fills in td's variables table with a row for each output produced by td or its
+// descendants.
+func (td *StepTemplateData) setVariables() {
+	td.Variables = td.variableRows()
+}
+
+// setGlossary fills in td's glossary with an entry for each output produced by td or its
+// descendants, and returns the anchors of the entries keyed by output name.
+//
+// The glossary's header is one level below td's section header, and its entries' headers are one
+// level below that.
+func (td *StepTemplateData) setGlossary() map[string]string {
+	level := func(n int) string {
+		if n > 6 {
+			n = 6
+		}
+		return strings.Repeat("#", n)
+	}
+	td.GlossaryHeader = level(td.headingLevel()+1) + " Glossary"
+
+	anchors := make(map[string]string)
+	td.Glossary = make([]GlossaryEntry, 0)
+	for _, row := range td.variableRows() {
+		header := fmt.Sprintf("%s @@%s@@", level(td.headingLevel()+2), row.Name)
+		entry := GlossaryEntry{VariableRow: row, Header: header, Anchor: headerAnchor(header)}
+		anchors[row.Name] = entry.Anchor
+		td.Glossary = append(td.Glossary, entry)
+	}
+	return anchors
+}
+
+// variableRows returns a VariableRow for each output produced by td or its descendants.
+func (td *StepTemplateData) variableRows() []VariableRow {
+	rows := make([]VariableRow, 0)
+	index := make(map[string]int)
+	var add func(StepTemplateData)
+	add = func(d StepTemplateData) {
+		link := StepLink{Name: d.StepName, Anchor: d.Anchor()}
+		for _, inputDef := range d.InputDefs {
+			if i, ok := index[inputDef.Name]; ok {
+				rows[i].Consumers = append(rows[i].Consumers, link)
+			}
+		}
+		for _, outputDef := range d.OutputDefs {
+			index[outputDef.Name] = len(rows)
+			rows = append(rows, VariableRow{
+				Name:      outputDef.Name,
+				ValueType: outputDef.ValueType,
+				Short:     outputDef.Short,
+				Producer:  link,
+			})
+		}
+		for _, c := range d.Children {
+			add(c)
+		}
+	}
+	add(*td)
+	return rows
+}
+
+// setHeadingOffset sets the HeadingOffset of td and all of its descendants to n.
+func (td *StepTemplateData) setHeadingOffset(n int) {
+	td.HeadingOffset = n
+	for i := range td.Children {
+		td.Children[i].setHeadingOffset(n)
+	}
+}
+
+// setStepComment sets the StepComment of td and all of its descendants to enabled.
+func (td *StepTemplateData) setStepComment(enabled bool) {
+	td.StepComment = enabled
+	for i := range td.Children {
+		td.Children[i].setStepComment(enabled)
+	}
+}
+
+// collapseChains returns a copy of td in which each chain of single-child steps among td's
+// descendants has been collapsed into a single step.
+//
+// A chain is a sequence of at least two steps, each of which has exactly one child, followed by
+// that last step's child. For example, if step A's only child is B, and B's only child is C, then
+// A, B, and C form a chain. (If B had no children, or more than one, there would be no chain.) A
+// step that repeats for a list is never collapsed into its parent.
+//
+// The collapsed step's title is made by joining the chain's titles with " / ", and its body,
+// ordered list of actions, rollback instructions, references, links, inputs, and outputs are the
+// concatenation of the chain's. It keeps the absolute name of the chain's first step, and the
+// names of the rest are listed in CollapsedNames. Its children are those of the last step in the
+// chain. The Depth and Pos of all descendants are recomputed to match the collapsed tree.
+func collapseChains(td StepTemplateData) StepTemplateData {
+	node := new(StepTemplateData)
+	*node = td
+	node.Children = make([]StepTemplateData, len(td.Children))
+	for i, c := range td.Children {
+		node.Children[i] = collapseChain(c, node, i)
+	}
+	return *node
+}
+
+// collapseChain collapses the chain, if any, that starts at td, and recursively collapses the
+// chains among its descendants.
+//
+// parent is the collapsed parent of td, and i is td's index among parent's children.
+func collapseChain(td StepTemplateData, parent *StepTemplateData, i int) StepTemplateData {
+	chain := []StepTemplateData{td}
+	for {
+		last := chain[len(chain)-1]
+		if len(last.Children) != 1 || last.Children[0].RepeatFor != "" {
+			break
+		}
+		chain = append(chain, last.Children[0])
+	}
+	if len(chain) < 3 {
+		chain = chain[:1]
+	}
+
+	titles := make([]string, 0)
+	bodies := make([]string, 0)
+	outcomes := make([]string, 0)
+	rollbacks := make([]string, 0)
+	node := new(StepTemplateData)
+	*node = td
+	node.References = make([]OutputReference, 0)
+	node.LongSteps = nil
+	node.Links = nil
+	node.InputDefs = make([]InputDef, 0)
+	node.OutputDefs = make([]OutputDef, 0)
+	for _, link := range chain {
+		titles = append(titles, link.Title)
+		if link.Body != "" {
+			bodies = append(bodies, link.Body)
+		}
+		if link.ExpectedOutcome != "" {
+			outcomes = append(outcomes, link.ExpectedOutcome)
+		}
+		if link.Rollback != "" {
+			rollbacks = append(rollbacks, link.Rollback)
+		}
+		node.References = append(node.References, link.References...)
+		node.LongSteps = append(node.LongSteps, link.LongSteps...)
+		node.Links = append(node.Links, link.Links...)
+		node.InputDefs = append(node.InputDefs, link.InputDefs...)
+		node.OutputDefs = append(node.OutputDefs, link.OutputDefs...)
+	}
+	last := chain[len(chain)-1]
+	node.Branch = last.Branch
+
+	node.Depth = parent.Depth + 1
+	node.Pos = append(append([]int{}, parent.Pos...), i)
+	node.CollapsedNames = make([]string, 0)
+	for _, link := range chain[1:] {
+		node.CollapsedNames = append(node.CollapsedNames, link.StepName)
+	}
+	node.Title = strings.Join(titles, " / ")
+	node.Body = strings.Join(bodies, "\n\n")
+	node.ExpectedOutcome = strings.Join(outcomes, "; ")
+	node.Rollback = strings.Join(rollbacks, "\n\n")
+	node.Parent = parent
+	node.Children = make([]StepTemplateData, len(last.Children))
+	for j, c := range last.Children {
+		node.Children[j] = collapseChain(c, node, j)
+	}
+	return *node
+}
+
+// escapeLiteralBodies escapes the markup metacharacters in td's body, and those of its
+// descendants, wherever the body was set by Step.LongLiteral, so that the body is rendered
+// literally in Markdown or AsciiDoc.
+func (td *StepTemplateData) escapeLiteralBodies() {
+	if td.LiteralBody {
+		td.Body = escapeMarkdown(unescapeStandins(td.Body))
+	}
+	for i := range td.Children {
+		td.Children[i].escapeLiteralBodies()
+	}
+}
+
+// pruneSteps returns a copy of td from which every descendant whose absolute name isn't in kept has
+// been removed, along with its own descendants.
+//
+// The Pos of the remaining descendants is recomputed to match the pruned tree. A branch one of
+// whose steps was removed is removed as well.
+func pruneSteps(td StepTemplateData, kept map[string]bool) StepTemplateData {
+	node := new(StepTemplateData)
+	*node = td
+	node.Children = make([]StepTemplateData, 0)
+	for _, c := range td.Children {
+		if kept[c.StepName] {
+			node.Children = append(node.Children, pruneStep(c, node, len(node.Children), kept))
+		}
+	}
+	if node.Branch != nil && !(kept[node.Branch.Yes.Name] && kept[node.Branch.No.Name]) {
+		node.Branch = nil
+	}
+	return *node
+}
+
+// pruneStep prunes the descendants of td as described in pruneSteps.
+//
+// parent is the pruned parent of td, and i is td's index among parent's children.
+func pruneStep(td StepTemplateData, parent *StepTemplateData, i int, kept map[string]bool) StepTemplateData {
+	td.Pos = append(append([]int{}, parent.Pos...), i)
+	td.Parent = parent
+	return pruneSteps(td, kept)
+}
+
 // numericPathToString renders td.Pos to a dot-separated string.
 //
 // If td.Pos is empty, numericPathToString returns the empty string.
@@ -222,15 +882,38 @@ func (td StepTemplateData) numericPathToString() string {
 // StepTemplateData struct will have Children == nil.
 func NewStepTemplateData(step *Step, parent *StepTemplateData, recursive bool) StepTemplateData {
 	td := StepTemplateData{
-		Depth:      step.Depth(),
-		Pos:        step.Pos(),
-		StepName:   step.AbsoluteName(),
-		Title:      step.GetShort(),
-		Body:       step.GetLong(),
-		InputDefs:  step.GetInputDefs(),
-		OutputDefs: step.GetOutputDefs(),
-		Parent:     parent,
-		Children:   nil,
+		Depth:           step.Depth(),
+		Pos:             step.Pos(),
+		StepName:        step.AbsoluteName(),
+		Title:           step.GetShort(),
+		Body:            step.GetLong(),
+		LiteralBody:     step.IsLongLiteral(),
+		LongSteps:       step.GetLongSteps(),
+		ExpectedOutcome: step.GetExpectedOutcome(),
+		Rollback:        step.GetRollback(),
+		RepeatFor:       step.GetRepeatFor(),
+		References:      make([]OutputReference, 0),
+		Links:           step.GetLinks(),
+		InputDefs:       step.GetInputDefs(),
+		OutputDefs:      step.GetOutputDefs(),
+		Parent:          parent,
+		Children:        nil,
+	}
+
+	if td.LiteralBody {
+		td.Body = escapeStandins(td.Body)
+	}
+
+	for _, name := range step.GetReferences() {
+		td.References = append(td.References, OutputReference{Name: name})
+	}
+
+	if branch := step.GetBranch(); branch != nil {
+		td.Branch = &BranchTemplateData{
+			Question: branch.Question,
+			Yes:      StepLink{Name: step.AbsoluteName() + "." + branch.YesStep},
+			No:       StepLink{Name: step.AbsoluteName() + "." + branch.NoStep},
+		}
 	}
 
 	if recursive {
@@ -17,8 +17,12 @@ func TestTemplateInputs(t *testing.T) {
 	assert := assert.New(t)
 
 	type testCase struct {
-		In  []InputDef
-		Out string
+		In []InputDef
+		// The style in which to render the inputs, and for InputStyleTable, the descriptions of
+		// the outputs they refer to.
+		Style        InputStyle
+		Descriptions map[string]string
+		Out          string
 	}
 
 	testCases := []testCase{
@@ -56,6 +60,33 @@ func TestTemplateInputs(t *testing.T) {
   - @@foo@@
   - @@bar@@`,
 		},
+		testCase{
+			In:    []InputDef{},
+			Style: InputStyleTable,
+			Out:   ``,
+		},
+		testCase{
+			In: []InputDef{
+				InputDef{
+					ValueType: "string",
+					Name:      "foo",
+					Required:  true,
+				},
+				InputDef{
+					ValueType: "int",
+					Name:      "bar",
+					Required:  false,
+				},
+			},
+			Style:        InputStyleTable,
+			Descriptions: map[string]string{"foo": "foo's short description | more"},
+			Out: `**Inputs**:
+
+| Name | Type | Required | Description |
+| --- | --- | --- | --- |
+| @@foo@@ | string | yes | foo's short description \| more |
+| @@bar@@ | int | no |  |`,
+		},
 	}
 
 	tpl, err := template.New("test").Parse(`{{template "inputs" .}}`)
@@ -63,11 +94,19 @@ func TestTemplateInputs(t *testing.T) {
 	AddTemplateInputs(tpl)
 	assert.Nil(err)
 
+	tableTpl, err := template.New("test").Parse(`{{template "inputs_table" .}}`)
+	assert.Nil(err)
+	AddTemplateInputsTable(tableTpl)
+
 	for i, tc := range testCases {
 		t.Logf("test case %d", i)
 
 		var b bytes.Buffer
-		err = tpl.Execute(&b, tc.In)
+		if tc.Style == InputStyleTable {
+			err = tableTpl.Execute(&b, newInputRows(tc.In, tc.Descriptions, nil))
+		} else {
+			err = tpl.Execute(&b, tc.In)
+		}
 		assert.Nil(err)
 		assert.Equal(tc.Out, b.String())
 	}
@@ -121,6 +160,32 @@ func TestTemplateOutputs(t *testing.T) {
   - @@foo@@ (string): foo's short description
   - @@bar@@ (int): bar's short description`,
 		},
+		testCase{
+			In: []OutputDef{
+				OutputDef{
+					ValueType: "string",
+					Name:      "foo",
+					Short:     "foo's short description",
+					Example:   "db-01",
+				},
+			},
+			Out: `**Outputs**:
+
+  - @@foo@@ (string): foo's short description (e.g. @@db-01@@)`,
+		},
+		testCase{
+			In: []OutputDef{
+				OutputDef{
+					ValueType: "string",
+					Name:      "foo",
+					Short:     "foo's short description",
+					Optional:  true,
+				},
+			},
+			Out: `**Outputs**:
+
+  - @@foo@@ (string): foo's short description (optional)`,
+		},
 	}
 
 	tpl, err := template.New("test").Parse(`{{template "outputs" .}}`)
@@ -371,6 +436,61 @@ body of the step
 INPUTS
 
 OUTPUTS`,
+		},
+		testCase{
+			In: StepTemplateData{
+				Depth:           2,
+				Pos:             []int{2, 8},
+				Title:           "step with body and expected outcome",
+				Body:            "body of the step",
+				ExpectedOutcome: "the light turns green",
+				InputDefs:       []InputDef{},
+				OutputDefs:      []OutputDef{OutputDef{}},
+				Children:        []StepTemplateData{},
+			},
+			Out: `### (2.8) step with body and expected outcome
+
+body of the step
+
+**Expected**: the light turns green
+
+OUTPUTS`,
+		},
+		testCase{
+			In: StepTemplateData{
+				Depth:      2,
+				Pos:        []int{2, 7},
+				Title:      "step with body and repetition",
+				Body:       "body of the step",
+				RepeatFor:  "HostNames",
+				InputDefs:  []InputDef{InputDef{}},
+				OutputDefs: []OutputDef{},
+				Children:   []StepTemplateData{},
+			},
+			Out: `### (2.7) step with body and repetition
+
+body of the step
+
+**Repeat** this step for each item in @@HostNames@@.
+
+INPUTS`,
+		},
+		testCase{
+			In: StepTemplateData{
+				Depth: 2,
+				Pos:   []int{2, 9},
+				Title: "step with references",
+				References: []OutputReference{
+					OutputReference{Name: "linked", Anchor: "#0-producer"},
+					OutputReference{Name: "unlinked"},
+				},
+				InputDefs:  []InputDef{},
+				OutputDefs: []OutputDef{},
+				Children:   []StepTemplateData{},
+			},
+			Out: `### (2.9) step with references
+
+**References**: [@@linked@@](#0-producer), @@unlinked@@`,
 		},
 		testCase{
 			In: StepTemplateData{
@@ -531,6 +651,20 @@ this is the description of my step`,
 
 this is the description of my step`,
 		},
+		testCase{
+			In: StepTemplateData{
+				Depth:           1,
+				Pos:             []int{1},
+				Title:           "step title",
+				Body:            "this is the description of my step",
+				ExpectedOutcome: "the light turns green",
+			},
+			Out: `## (1) step title
+
+this is the description of my step
+
+Expected: the light turns green`,
+		},
 	}
 
 	tpl := template.New("test")
@@ -558,6 +692,39 @@ func TestStepTemplateData_SectionHeader(t *testing.T) {
 		}
 		assert.Equal("### (0.2) Short description of step", templateData.SectionHeader())
 	}
+
+	// With heading offset
+	{
+		templateData := StepTemplateData{
+			Depth:         0,
+			Pos:           []int{},
+			Title:         "Root step",
+			HeadingOffset: 1,
+		}
+		assert.Equal("## Root step", templateData.SectionHeader())
+	}
+
+	// With heading offset that would go beyond six levels
+	{
+		templateData := StepTemplateData{
+			Depth:         4,
+			Pos:           []int{0, 1, 2, 3},
+			Title:         "Deep step",
+			HeadingOffset: 3,
+		}
+		assert.Equal("###### (0.1.2.3) Deep step", templateData.SectionHeader())
+	}
+
+	// With negative heading offset that would go above one level
+	{
+		templateData := StepTemplateData{
+			Depth:         1,
+			Pos:           []int{2},
+			Title:         "Shallow step",
+			HeadingOffset: -3,
+		}
+		assert.Equal("# (2) Shallow step", templateData.SectionHeader())
+	}
 }
 
 func TestStepTemplateData_Anchor(t *testing.T) {
@@ -0,0 +1,94 @@
+package donothing
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// SetVars sets the variables that can be substituted into step descriptions.
+//
+// Once SetVars has been called, the short and long descriptions of every step are processed as
+// text/template templates when the procedure is rendered or executed. A description can refer to
+// a variable as {{.Var "name"}}, which is replaced with the variable's value, so that one
+// procedure can be reused for different services, clusters, and so on. No functions are available
+// to the templates beyond text/template's builtins.
+//
+// Referring to a variable that isn't in vars is an error, which Check reports as a problem. If
+// SetVars is never called, descriptions are used as given, even if they contain "{{".
+func (pcd *Procedure) SetVars(vars map[string]string) {
+	pcd.vars = make(map[string]string)
+	for name, value := range vars {
+		pcd.vars[name] = value
+	}
+}
+
+// templateVars is the data passed to description templates. See SetVars.
+type templateVars struct {
+	vars map[string]string
+	// The name of the first variable that was referred to but isn't set, if any.
+	missing string
+}
+
+// Var returns the value of the named variable.
+func (tv *templateVars) Var(name string) (string, error) {
+	value, ok := tv.vars[name]
+	if !ok {
+		if tv.missing == "" {
+			tv.missing = name
+		}
+		return "", fmt.Errorf("variable '%s' is not set", name)
+	}
+	return value, nil
+}
+
+// expandVars substitutes the procedure's variables into s, as described in SetVars.
+//
+// If SetVars hasn't been called, s is returned unchanged.
+func (pcd *Procedure) expandVars(s string) (string, error) {
+	if pcd.vars == nil || !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	tpl, err := template.New("description").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	tv := &templateVars{vars: pcd.vars}
+	var b strings.Builder
+	if err := tpl.Execute(&b, tv); err != nil {
+		if tv.missing != "" {
+			return "", fmt.Errorf("variable '%s' is not set", tv.missing)
+		}
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// expandTemplateData substitutes the procedure's variables into the title and body of td and its
+// descendants.
+func (pcd *Procedure) expandTemplateData(td *StepTemplateData) error {
+	var err error
+	if td.Title, err = pcd.expandVars(td.Title); err != nil {
+		return fmt.Errorf("Short description of step '%s': %w", td.StepName, err)
+	}
+	if !td.LiteralBody {
+		if td.Body, err = pcd.expandVars(td.Body); err != nil {
+			return fmt.Errorf("Long description of step '%s': %w", td.StepName, err)
+		}
+	}
+	for i := range td.LongSteps {
+		if td.LongSteps[i], err = pcd.expandVars(td.LongSteps[i]); err != nil {
+			return fmt.Errorf("Long steps of step '%s': %w", td.StepName, err)
+		}
+	}
+	if td.Rollback, err = pcd.expandVars(td.Rollback); err != nil {
+		return fmt.Errorf("Rollback instructions of step '%s': %w", td.StepName, err)
+	}
+	for i := range td.Children {
+		if err := pcd.expandTemplateData(&td.Children[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
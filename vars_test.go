@@ -0,0 +1,79 @@
+package donothing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Variables set with SetVars should be substituted into descriptions when rendering and executing.
+func TestProcedure_SetVars(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short(`Restart {{.Var "service"}}`)
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short(`Drain traffic from {{.Var "service"}}`)
+		step.Long(`Run @@drain {{.Var "service"}} --cluster={{.Var "cluster"}}@@.`)
+	})
+	pcd.SetVars(map[string]string{"service": "billing", "cluster": "prod-1"})
+
+	problems, err := pcd.Check()
+	assert.Nil(err)
+	assert.Equal([]string{}, problems)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "# Restart billing\n")
+	assert.Contains(b.String(), "## (0) Drain traffic from billing\n")
+	assert.Contains(b.String(), "Run `drain billing --cluster=prod-1`.")
+
+	pcd.stdin = strings.NewReader("\n\n")
+	var stdout bytes.Buffer
+	pcd.stdout = &stdout
+	assert.Nil(pcd.Execute())
+	assert.Contains(stdout.String(), "## (0) Drain traffic from billing")
+	assert.Contains(stdout.String(), "Run `drain billing --cluster=prod-1`.")
+}
+
+// A description that refers to an unset variable should be reported by Check, and should keep the
+// procedure from being rendered.
+func TestProcedure_SetVars_Missing(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short(`Restart {{.Var "service"}}`)
+	pcd.AddStep(func(step *Step) {
+		step.Name("drain")
+		step.Short("Drain traffic")
+		step.Long(`Run @@drain --cluster={{.Var "cluster"}}@@.`)
+	})
+	pcd.SetVars(map[string]string{"service": "billing"})
+
+	problems, err := pcd.Check()
+	assert.NotNil(err)
+	assert.Equal([]string{"Long description of step 'root.drain': variable 'cluster' is not set"}, problems)
+
+	var b bytes.Buffer
+	assert.NotNil(pcd.Render(&b))
+	assert.Equal("", b.String())
+}
+
+// Without SetVars, descriptions should be used as given.
+func TestProcedure_SetVars_Unset(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	pcd := NewProcedure()
+	pcd.Short("Render the chart")
+	pcd.Long(`Check that @@{{ .Values.replicas }}@@ is set in the chart.`)
+
+	var b bytes.Buffer
+	assert.Nil(pcd.Render(&b))
+	assert.Contains(b.String(), "Check that `{{ .Values.replicas }}` is set in the chart.")
+}
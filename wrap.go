@@ -0,0 +1,55 @@
+package donothing
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// wrapText word-wraps the lines of s so that no line is longer than width characters.
+//
+// Lines are wrapped individually, so intentional line breaks and blank lines in s are preserved.
+// Lines that begin with whitespace are assumed to be part of an indented code block and are left
+// untouched. A single word longer than width is placed on a line by itself rather than being
+// broken.
+//
+// If width is less than 1, s is returned unchanged.
+func wrapText(s string, width int) string {
+	if width < 1 {
+		return s
+	}
+
+	rslt := make([]string, 0)
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" || strings.TrimLeft(line, " \t") != line {
+			rslt = append(rslt, line)
+			continue
+		}
+		rslt = append(rslt, wrapLine(line, width)...)
+	}
+	return strings.Join(rslt, "\n")
+}
+
+// wrapLine breaks line into a sequence of lines no longer than width characters, splitting only at
+// whitespace.
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return []string{line}
+	}
+
+	lines := make([]string, 0)
+	cur := words[0]
+	for _, word := range words[1:] {
+		if utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, cur)
+			cur = word
+			continue
+		}
+		cur = cur + " " + word
+	}
+	return append(lines, cur)
+}
@@ -0,0 +1,68 @@
+package donothing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wrapText should wrap each line at the given width, leaving indented lines alone.
+func TestWrapText(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type testCase struct {
+		In    string
+		Width int
+		Out   string
+	}
+
+	testCases := []testCase{
+		// No wrapping when width is 0
+		testCase{
+			In:    "aaa bbb ccc",
+			Width: 0,
+			Out:   "aaa bbb ccc",
+		},
+		// Line exactly at the boundary is left alone
+		testCase{
+			In:    "aaa bbb ccc",
+			Width: 11,
+			Out:   "aaa bbb ccc",
+		},
+		// Line one character past the boundary is wrapped
+		testCase{
+			In:    "aaa bbb ccc",
+			Width: 10,
+			Out:   "aaa bbb\nccc",
+		},
+		testCase{
+			In:    "aaa bbb ccc ddd eee",
+			Width: 7,
+			Out:   "aaa bbb\nccc ddd\neee",
+		},
+		// Words longer than the width get their own line
+		testCase{
+			In:    "a bbbbbbbbbb c",
+			Width: 5,
+			Out:   "a\nbbbbbbbbbb\nc",
+		},
+		// Blank lines are preserved
+		testCase{
+			In:    "aaa bbb\n\n\nccc ddd",
+			Width: 3,
+			Out:   "aaa\nbbb\n\n\nccc\nddd",
+		},
+		// Indented code blocks aren't wrapped
+		testCase{
+			In:    "aaa bbb\n\n    if (x) { return y; }\n\tfoo bar baz\n\nccc ddd",
+			Width: 5,
+			Out:   "aaa\nbbb\n\n    if (x) { return y; }\n\tfoo bar baz\n\nccc\nddd",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("test case %d", i)
+		assert.Equal(tc.Out, wrapText(tc.In, tc.Width))
+	}
+}